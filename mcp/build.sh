@@ -3,7 +3,7 @@
 echo "Building Rave MCP Server..."
 
 # Build the Go binary
-go build -o rave-mcp-go rave-mcp.go
+go build -o rave-mcp-go .
 
 if [ $? -eq 0 ]; then
     echo "✅ Binary built successfully: rave-mcp-go"
@@ -0,0 +1,387 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CampaignStatus is where a campaign is in its lifecycle. Campaigns start as
+// drafts and move between statuses only through campaignActions:
+//
+//	draft     -> active, archived
+//	active    -> paused, completed
+//	paused    -> active, completed
+//	completed -> archived
+//
+// Archived campaigns are kept for reference and can no longer change.
+type CampaignStatus string
+
+const (
+	StatusDraft     CampaignStatus = "draft"
+	StatusActive    CampaignStatus = "active"
+	StatusPaused    CampaignStatus = "paused"
+	StatusCompleted CampaignStatus = "completed"
+	StatusArchived  CampaignStatus = "archived"
+)
+
+// campaignAction is a lifecycle transition a user can ask for.
+type campaignAction struct {
+	name string
+	from []CampaignStatus
+	to   CampaignStatus
+}
+
+// campaignActions are the only ways a campaign's status changes.
+var campaignActions = []campaignAction{
+	{name: "activate", from: []CampaignStatus{StatusDraft}, to: StatusActive},
+	{name: "pause", from: []CampaignStatus{StatusActive}, to: StatusPaused},
+	{name: "resume", from: []CampaignStatus{StatusPaused}, to: StatusActive},
+	{name: "complete", from: []CampaignStatus{StatusActive, StatusPaused}, to: StatusCompleted},
+	{name: "archive", from: []CampaignStatus{StatusDraft, StatusCompleted}, to: StatusArchived},
+}
+
+func findCampaignAction(name string) (campaignAction, bool) {
+	for _, action := range campaignActions {
+		if action.name == name {
+			return action, true
+		}
+	}
+	return campaignAction{}, false
+}
+
+// apply moves the campaign to the action's status, or explains why it
+// cannot.
+func (a campaignAction) apply(c *Campaign) error {
+	if !slices.Contains(a.from, c.Status) {
+		from := make([]string, len(a.from))
+		for i, status := range a.from {
+			from[i] = string(status)
+		}
+		return fmt.Errorf("cannot %s campaign %s because it is %s; only %s campaigns can be %s",
+			a.name, c.ID, c.Status, strings.Join(from, " or "), pastTense(a.name))
+	}
+	c.Status = a.to
+	return nil
+}
+
+func pastTense(verb string) string {
+	if strings.HasSuffix(verb, "e") {
+		return verb + "d"
+	}
+	return verb + "ed"
+}
+
+// editable reports whether a campaign's details can still be changed.
+func (c Campaign) editable() error {
+	switch c.Status {
+	case StatusCompleted, StatusArchived:
+		return fmt.Errorf("campaign %s is %s and can no longer be edited", c.ID, c.Status)
+	}
+	return nil
+}
+
+// Campaign is a marketing campaign as returned by the campaign tools. The
+// store fills in ID, Status, Version and the timestamps.
+type Campaign struct {
+	ID           string         `json:"id" description:"Campaign ID"`
+	CampaignName string         `json:"campaign_name" description:"Campaign name"`
+	ClientName   string         `json:"client_name" description:"Client the campaign is for"`
+	Description  string         `json:"description" description:"What the campaign is about"`
+	Budget       *float64       `json:"budget,omitempty" description:"Campaign budget in dollars"`
+	Channels     []string       `json:"channels,omitempty" description:"Marketing channels"`
+	Status       CampaignStatus `json:"status" description:"Lifecycle status" enum:"draft|active|paused|completed|archived"`
+	Version      int            `json:"version" description:"Incremented on every change"`
+	CreatedAt    time.Time      `json:"created_at" description:"When the campaign was created"`
+	UpdatedAt    time.Time      `json:"updated_at" description:"When the campaign last changed"`
+}
+
+// Actor identifies who made a change to a campaign: the MCP client and the
+// session it used.
+type Actor struct {
+	Client        string `json:"client,omitempty" description:"Name of the MCP client"`
+	ClientVersion string `json:"client_version,omitempty" description:"Version of the MCP client"`
+	Session       string `json:"session,omitempty" description:"Server session the change came through"`
+}
+
+func (a Actor) String() string {
+	if a.Client == "" && a.Session == "" {
+		return "unknown"
+	}
+	name := strings.TrimSpace(a.Client + " " + a.ClientVersion)
+	if name == "" {
+		name = "unnamed client"
+	}
+	if a.Session != "" {
+		name += " (session " + a.Session[:min(8, len(a.Session))] + ")"
+	}
+	return name
+}
+
+type actorKey struct{}
+
+// withActor records who the work done under ctx is on behalf of.
+func withActor(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+func actorFrom(ctx context.Context) Actor {
+	actor, _ := ctx.Value(actorKey{}).(Actor)
+	return actor
+}
+
+// Event types for CampaignEvent.
+const (
+	EventCreated       = "created"
+	EventUpdated       = "updated"
+	EventStatusChanged = "status_changed"
+)
+
+// CampaignEvent records one change to a campaign: the version it produced,
+// who made it, when, and what changed.
+type CampaignEvent struct {
+	Version int           `json:"version" description:"Campaign version this change produced"`
+	Type    string        `json:"type" description:"Kind of change" enum:"created|updated|status_changed"`
+	Actor   Actor         `json:"actor" description:"Who made the change"`
+	At      time.Time     `json:"at" description:"When the change was made"`
+	Changes []FieldChange `json:"changes,omitempty" description:"Fields that changed"`
+}
+
+// FieldChange is one field's value before and after a change. A value that
+// was or became unset is left out.
+type FieldChange struct {
+	Field string      `json:"field" description:"Field name, as in the campaign"`
+	Old   interface{} `json:"old,omitempty" description:"Value before the change"`
+	New   interface{} `json:"new,omitempty" description:"Value after the change"`
+}
+
+// CampaignRevision is a campaign as it was at one version, with the event
+// that produced it.
+type CampaignRevision struct {
+	Campaign Campaign      `json:"campaign"`
+	Event    CampaignEvent `json:"event"`
+}
+
+// newCampaignEvent describes the change from prev, nil for a new campaign, to
+// next.
+func newCampaignEvent(prev *Campaign, next Campaign, actor Actor) CampaignEvent {
+	event := CampaignEvent{
+		Version: next.Version,
+		Type:    EventUpdated,
+		Actor:   actor,
+		At:      next.UpdatedAt,
+	}
+
+	var before Campaign
+	switch {
+	case prev == nil:
+		event.Type = EventCreated
+	case prev.Status != next.Status:
+		event.Type = EventStatusChanged
+	}
+	if prev != nil {
+		before = *prev
+	}
+	event.Changes = diffCampaigns(before, next)
+	return event
+}
+
+// diffCampaigns lists the user-visible fields that differ between two
+// campaigns, in a fixed order.
+func diffCampaigns(a, b Campaign) []FieldChange {
+	var changes []FieldChange
+	add := func(field string, old, new interface{}) {
+		changes = append(changes, FieldChange{Field: field, Old: old, New: new})
+	}
+	optional := func(s string) interface{} {
+		if s == "" {
+			return nil
+		}
+		return s
+	}
+
+	if a.CampaignName != b.CampaignName {
+		add("campaign_name", optional(a.CampaignName), optional(b.CampaignName))
+	}
+	if a.ClientName != b.ClientName {
+		add("client_name", optional(a.ClientName), optional(b.ClientName))
+	}
+	if a.Description != b.Description {
+		add("description", optional(a.Description), optional(b.Description))
+	}
+	if budgetValue(a.Budget) != budgetValue(b.Budget) {
+		add("budget", budgetValue(a.Budget), budgetValue(b.Budget))
+	}
+	if !slices.Equal(a.Channels, b.Channels) {
+		var old, new interface{}
+		if len(a.Channels) > 0 {
+			old = a.Channels
+		}
+		if len(b.Channels) > 0 {
+			new = b.Channels
+		}
+		add("channels", old, new)
+	}
+	if a.Status != b.Status {
+		add("status", optional(string(a.Status)), optional(string(b.Status)))
+	}
+	return changes
+}
+
+func budgetValue(budget *float64) interface{} {
+	if budget == nil {
+		return nil
+	}
+	return *budget
+}
+
+// ErrCampaignNotFound is returned for an ID the store does not have.
+var ErrCampaignNotFound = errors.New("campaign not found")
+
+// ErrCampaignExists is returned by Create for an ID that is already taken.
+var ErrCampaignExists = errors.New("campaign already exists")
+
+// VersionConflictError is returned when a campaign was changed by someone
+// else since the version the caller based its change on.
+type VersionConflictError struct {
+	Expected int
+	Current  Campaign
+}
+
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("campaign %s was changed since version %d and is now at version %d",
+		e.Current.ID, e.Expected, e.Current.Version)
+}
+
+// CampaignStore keeps campaigns and their history. Every change is recorded
+// as a CampaignEvent attributed to the actor in the context (see withActor)
+// and is never rewritten. Implementations must be safe for concurrent use,
+// since tool calls from every session share one store.
+type CampaignStore interface {
+	// Create saves a new campaign and returns it with its status, version
+	// and timestamps filled in. An ID is generated unless the campaign
+	// already has one, which must not be taken (ErrCampaignExists).
+	Create(ctx context.Context, campaign Campaign) (Campaign, error)
+
+	// Get returns a campaign by ID, or ErrCampaignNotFound.
+	Get(ctx context.Context, id string) (Campaign, error)
+
+	// List returns the campaigns matching filter, newest first.
+	List(ctx context.Context, filter CampaignFilter) ([]Campaign, error)
+
+	// Update applies change to a copy of the campaign and saves the result
+	// as the next version. If version is not zero and the campaign is no
+	// longer at that version, it returns a *VersionConflictError without
+	// calling change. An error from change aborts the update and is
+	// returned as is.
+	Update(ctx context.Context, id string, version int, change func(*Campaign) error) (Campaign, error)
+
+	// History returns every version of a campaign, oldest first, or
+	// ErrCampaignNotFound.
+	History(ctx context.Context, id string) ([]CampaignRevision, error)
+}
+
+// CampaignFilter selects campaigns. Zero fields match everything.
+type CampaignFilter struct {
+	// ClientName matches campaigns whose client name contains it, ignoring
+	// case
+	ClientName string
+	Status     CampaignStatus
+	// Channel matches campaigns that use it, ignoring case
+	Channel       string
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+}
+
+func (f CampaignFilter) matches(c Campaign) bool {
+	if f.ClientName != "" && !strings.Contains(strings.ToLower(c.ClientName), strings.ToLower(f.ClientName)) {
+		return false
+	}
+	if f.Status != "" && c.Status != f.Status {
+		return false
+	}
+	if f.Channel != "" && !slices.ContainsFunc(c.Channels, func(ch string) bool { return strings.EqualFold(ch, f.Channel) }) {
+		return false
+	}
+	if !f.CreatedAfter.IsZero() && c.CreatedAt.Before(f.CreatedAfter) {
+		return false
+	}
+	if !f.CreatedBefore.IsZero() && !c.CreatedAt.Before(f.CreatedBefore) {
+		return false
+	}
+	return true
+}
+
+// newerFirst orders campaigns by creation time, newest first, with the ID
+// breaking ties so the order is stable for pagination.
+func newerFirst(a, b Campaign) int {
+	if c := b.CreatedAt.Compare(a.CreatedAt); c != 0 {
+		return c
+	}
+	return strings.Compare(b.ID, a.ID)
+}
+
+// newCampaign stamps a campaign that is about to be stored for the first
+// time.
+func newCampaign(campaign Campaign, now time.Time) Campaign {
+	if campaign.ID == "" {
+		campaign.ID = newCampaignID()
+	}
+	campaign.Status = StatusDraft
+	campaign.Version = 1
+	campaign.CreatedAt = now
+	campaign.UpdatedAt = now
+	return campaign
+}
+
+func newCampaignID() string {
+	b := make([]byte, 6)
+	rand.Read(b)
+	return "cmp_" + hex.EncodeToString(b)
+}
+
+// dataDir is where rave keeps its files: $RAVE_DATA_DIR if set, otherwise the
+// rave folder in the user's config directory, next to config.json.
+func dataDir() (string, error) {
+	if dir := os.Getenv("RAVE_DATA_DIR"); dir != "" {
+		return dir, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("cannot find a place to store data, set RAVE_DATA_DIR: %w", err)
+	}
+	return filepath.Join(dir, "rave"), nil
+}
+
+var (
+	campaignsOnce sync.Once
+	campaignStore CampaignStore
+	campaignsErr  error
+)
+
+// campaigns returns the shared campaign store, opening it on first use so
+// that a server which never touches campaigns never touches the disk.
+func campaigns() (CampaignStore, error) {
+	campaignsOnce.Do(func() {
+		dir, err := dataDir()
+		if err != nil {
+			campaignsErr = err
+			return
+		}
+		store, err := OpenJSONLCampaignStore(filepath.Join(dir, "campaigns.jsonl"))
+		if err != nil {
+			campaignsErr = err
+			return
+		}
+		campaignStore = notifyingCampaignStore{store}
+	})
+	return campaignStore, campaignsErr
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// JSONLCampaignStore keeps campaigns in a JSON-lines file. Every change
+// appends the campaign's new state, together with the event describing the
+// change, as one line and the last line for an ID is the campaign's current
+// state. A write never rewrites what is already on disk, so the file doubles
+// as the audit trail and a crash can at worst lose the line being written.
+//
+// Claude Desktop can run several copies of the server at once, so before each
+// operation the store reads whatever other processes have appended since it
+// last looked. Writes hold a lock on the file from that read until the new
+// line is written, so a version check cannot pass on a stale read.
+type JSONLCampaignStore struct {
+	path string
+
+	mu      sync.Mutex
+	offset  int64
+	history map[string][]CampaignRevision
+	order   []string
+}
+
+// storedCampaign is one line of the file. Lines written before events were
+// recorded have no event; one is reconstructed from the previous line.
+type storedCampaign struct {
+	Campaign
+	Event *CampaignEvent `json:"event,omitempty"`
+}
+
+// OpenJSONLCampaignStore opens the store at path, creating its directory if
+// needed. The file itself is created by the first write.
+func OpenJSONLCampaignStore(path string) (*JSONLCampaignStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("creating campaign store directory: %w", err)
+	}
+
+	s := &JSONLCampaignStore{path: path, history: make(map[string][]CampaignRevision)}
+	if err := s.refresh(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *JSONLCampaignStore) Create(ctx context.Context, campaign Campaign) (Campaign, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	unlock, err := lockFile(s.path)
+	if err != nil {
+		return Campaign{}, err
+	}
+	defer unlock()
+
+	if err := s.refresh(); err != nil {
+		return Campaign{}, err
+	}
+
+	if _, exists := s.current(campaign.ID); campaign.ID != "" && exists {
+		return Campaign{}, ErrCampaignExists
+	}
+
+	campaign = newCampaign(campaign, time.Now().UTC())
+	event := newCampaignEvent(nil, campaign, actorFrom(ctx))
+	if err := s.append(campaign, event); err != nil {
+		return Campaign{}, err
+	}
+	return campaign, nil
+}
+
+func (s *JSONLCampaignStore) Get(ctx context.Context, id string) (Campaign, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.refresh(); err != nil {
+		return Campaign{}, err
+	}
+
+	campaign, ok := s.current(id)
+	if !ok {
+		return Campaign{}, ErrCampaignNotFound
+	}
+	return campaign, nil
+}
+
+func (s *JSONLCampaignStore) History(ctx context.Context, id string) ([]CampaignRevision, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.refresh(); err != nil {
+		return nil, err
+	}
+
+	revisions, ok := s.history[id]
+	if !ok {
+		return nil, ErrCampaignNotFound
+	}
+	return slices.Clone(revisions), nil
+}
+
+func (s *JSONLCampaignStore) Update(ctx context.Context, id string, version int, change func(*Campaign) error) (Campaign, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	unlock, err := lockFile(s.path)
+	if err != nil {
+		return Campaign{}, err
+	}
+	defer unlock()
+
+	if err := s.refresh(); err != nil {
+		return Campaign{}, err
+	}
+
+	current, ok := s.current(id)
+	if !ok {
+		return Campaign{}, ErrCampaignNotFound
+	}
+	if version != 0 && version != current.Version {
+		return Campaign{}, &VersionConflictError{Expected: version, Current: current}
+	}
+
+	updated := current
+	updated.Channels = slices.Clone(current.Channels)
+	if err := change(&updated); err != nil {
+		return Campaign{}, err
+	}
+
+	// The record's identity and bookkeeping are the store's, whatever
+	// change did to them
+	updated.ID = current.ID
+	updated.CreatedAt = current.CreatedAt
+	updated.Version = current.Version + 1
+	updated.UpdatedAt = time.Now().UTC()
+
+	event := newCampaignEvent(&current, updated, actorFrom(ctx))
+	if err := s.append(updated, event); err != nil {
+		return Campaign{}, err
+	}
+	return updated, nil
+}
+
+func (s *JSONLCampaignStore) List(ctx context.Context, filter CampaignFilter) ([]Campaign, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.refresh(); err != nil {
+		return nil, err
+	}
+
+	var matched []Campaign
+	for _, id := range s.order {
+		if campaign, _ := s.current(id); filter.matches(campaign) {
+			matched = append(matched, campaign)
+		}
+	}
+	slices.SortFunc(matched, newerFirst)
+	return matched, nil
+}
+
+// current returns the latest version of a campaign. Callers hold s.mu.
+func (s *JSONLCampaignStore) current(id string) (Campaign, bool) {
+	revisions := s.history[id]
+	if len(revisions) == 0 {
+		return Campaign{}, false
+	}
+	return revisions[len(revisions)-1].Campaign, true
+}
+
+// append writes a campaign's new state and applies it. Callers hold s.mu.
+func (s *JSONLCampaignStore) append(campaign Campaign, event CampaignEvent) error {
+	line, err := json.Marshal(storedCampaign{Campaign: campaign, Event: &event})
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	f, err := os.OpenFile(s.path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return fmt.Errorf("opening campaign store: %w", err)
+	}
+	defer f.Close()
+
+	// A line torn by a crash would swallow this one too, so it is ended
+	// first. Callers hold the file lock, so no one else is mid-write.
+	torn, err := endsMidLine(f)
+	if err != nil {
+		return fmt.Errorf("reading campaign store: %w", err)
+	}
+	if torn {
+		line = append([]byte{'\n'}, line...)
+	}
+
+	if _, err := f.Write(line); err != nil {
+		return fmt.Errorf("writing campaign store: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("writing campaign store: %w", err)
+	}
+
+	// Another process may have appended in between, so the new line is
+	// picked up by reading forward rather than by bumping the offset
+	return s.refresh()
+}
+
+// endsMidLine reports whether a JSON-lines file ends in a line without its
+// newline, as a crash in the middle of a write leaves it.
+func endsMidLine(f *os.File) (bool, error) {
+	info, err := f.Stat()
+	if err != nil || info.Size() == 0 {
+		return false, err
+	}
+	last := make([]byte, 1)
+	if _, err := f.ReadAt(last, info.Size()-1); err != nil {
+		return false, err
+	}
+	return last[0] != '\n', nil
+}
+
+// refresh applies lines appended since the last refresh. A trailing line
+// without a newline is still being written and is left for next time. Lines
+// that do not parse are skipped so that one bad line cannot lock anyone out
+// of their campaigns. Callers hold s.mu.
+func (s *JSONLCampaignStore) refresh() error {
+	f, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading campaign store: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Seek(s.offset, io.SeekStart); err != nil {
+		return fmt.Errorf("reading campaign store: %w", err)
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return fmt.Errorf("reading campaign store: %w", err)
+	}
+
+	end := bytes.LastIndexByte(data, '\n')
+	if end < 0 {
+		return nil
+	}
+
+	for _, line := range bytes.Split(data[:end], []byte{'\n'}) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		var stored storedCampaign
+		if err := json.Unmarshal(line, &stored); err != nil || stored.ID == "" {
+			fmt.Fprintf(os.Stderr, "Skipping unreadable line in %s: %s\n", s.path, line)
+			continue
+		}
+
+		previous, exists := s.current(stored.ID)
+		if !exists {
+			s.order = append(s.order, stored.ID)
+		}
+
+		if stored.Event == nil {
+			var prev *Campaign
+			if exists {
+				prev = &previous
+			}
+			event := newCampaignEvent(prev, stored.Campaign, Actor{})
+			stored.Event = &event
+		}
+
+		s.history[stored.ID] = append(s.history[stored.ID], CampaignRevision{
+			Campaign: stored.Campaign,
+			Event:    *stored.Event,
+		})
+	}
+
+	s.offset += int64(end) + 1
+	return nil
+}
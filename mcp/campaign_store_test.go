@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestJSONLCampaignStoreSurvivesTornLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "campaigns.jsonl")
+	ctx := context.Background()
+
+	store, err := OpenJSONLCampaignStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := store.Create(ctx, Campaign{CampaignName: "First"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A crash halfway through writing the next line
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"id":"cmp_torn","campaign_na`)
+	f.Close()
+
+	// A fresh store, as after a restart
+	store, err = OpenJSONLCampaignStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := store.Create(ctx, Campaign{CampaignName: "Second"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := OpenJSONLCampaignStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []Campaign{first, second} {
+		got, err := reopened.Get(ctx, want.ID)
+		if err != nil {
+			t.Fatalf("Get(%s) after a torn line: %v", want.CampaignName, err)
+		}
+		if got.CampaignName != want.CampaignName {
+			t.Errorf("Get(%s) = %q", want.ID, got.CampaignName)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCampaignActionApply(t *testing.T) {
+	tests := []struct {
+		action  string
+		from    CampaignStatus
+		want    CampaignStatus
+		wantErr string
+	}{
+		{action: "activate", from: StatusDraft, want: StatusActive},
+		{action: "pause", from: StatusActive, want: StatusPaused},
+		{action: "resume", from: StatusPaused, want: StatusActive},
+		{action: "complete", from: StatusActive, want: StatusCompleted},
+		{action: "complete", from: StatusPaused, want: StatusCompleted},
+		{action: "archive", from: StatusDraft, want: StatusArchived},
+		{action: "archive", from: StatusCompleted, want: StatusArchived},
+		{
+			action:  "activate",
+			from:    StatusArchived,
+			wantErr: "cannot activate campaign cmp_1 because it is archived; only draft campaigns can be activated",
+		},
+		{
+			action:  "pause",
+			from:    StatusPaused,
+			wantErr: "cannot pause campaign cmp_1 because it is paused; only active campaigns can be paused",
+		},
+		{
+			action:  "complete",
+			from:    StatusDraft,
+			wantErr: "cannot complete campaign cmp_1 because it is draft; only active or paused campaigns can be completed",
+		},
+		{
+			action:  "archive",
+			from:    StatusActive,
+			wantErr: "cannot archive campaign cmp_1 because it is active; only draft or completed campaigns can be archived",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.action+" "+string(tt.from), func(t *testing.T) {
+			action, ok := findCampaignAction(tt.action)
+			if !ok {
+				t.Fatalf("no action %q", tt.action)
+			}
+
+			c := Campaign{ID: "cmp_1", Status: tt.from}
+			err := action.apply(&c)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("apply() error = %v, want %q", err, tt.wantErr)
+				}
+				if c.Status != tt.from {
+					t.Errorf("status changed to %s on a refused action", c.Status)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("apply() error = %v", err)
+			}
+			if c.Status != tt.want {
+				t.Errorf("status = %s, want %s", c.Status, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffCampaigns(t *testing.T) {
+	budget := func(f float64) *float64 { return &f }
+	base := Campaign{
+		ID:           "cmp_1",
+		CampaignName: "Spring",
+		ClientName:   "ABC",
+		Description:  "Flu shots",
+		Budget:       budget(100),
+		Channels:     []string{"email"},
+		Status:       StatusDraft,
+		Version:      1,
+	}
+
+	tests := []struct {
+		name   string
+		change func(*Campaign)
+		want   []FieldChange
+	}{
+		{
+			name: "no change",
+		},
+		{
+			name:   "bookkeeping is not a change",
+			change: func(c *Campaign) { c.Version = 2; c.ID = "cmp_2" },
+		},
+		{
+			name:   "text fields",
+			change: func(c *Campaign) { c.CampaignName = "Summer"; c.Description = "" },
+			want: []FieldChange{
+				{Field: "campaign_name", Old: "Spring", New: "Summer"},
+				{Field: "description", Old: "Flu shots"},
+			},
+		},
+		{
+			name:   "budget changed",
+			change: func(c *Campaign) { c.Budget = budget(250) },
+			want:   []FieldChange{{Field: "budget", Old: 100.0, New: 250.0}},
+		},
+		{
+			name:   "same budget in a different pointer",
+			change: func(c *Campaign) { c.Budget = budget(100) },
+		},
+		{
+			name:   "budget cleared",
+			change: func(c *Campaign) { c.Budget = nil },
+			want:   []FieldChange{{Field: "budget", Old: 100.0}},
+		},
+		{
+			name:   "channels",
+			change: func(c *Campaign) { c.Channels = []string{"email", "social"} },
+			want:   []FieldChange{{Field: "channels", Old: []string{"email"}, New: []string{"email", "social"}}},
+		},
+		{
+			name:   "channels cleared",
+			change: func(c *Campaign) { c.Channels = nil },
+			want:   []FieldChange{{Field: "channels", Old: []string{"email"}}},
+		},
+		{
+			name:   "status and client in field order",
+			change: func(c *Campaign) { c.Status = StatusActive; c.ClientName = "XYZ" },
+			want: []FieldChange{
+				{Field: "client_name", Old: "ABC", New: "XYZ"},
+				{Field: "status", Old: "draft", New: "active"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changed := base
+			changed.Channels = append([]string{}, base.Channels...)
+			if tt.change != nil {
+				tt.change(&changed)
+			}
+			got := diffCampaigns(base, changed)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("diffCampaigns() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("new campaign", func(t *testing.T) {
+		want := []FieldChange{
+			{Field: "campaign_name", New: "Spring"},
+			{Field: "client_name", New: "ABC"},
+			{Field: "description", New: "Flu shots"},
+			{Field: "budget", New: 100.0},
+			{Field: "channels", New: []string{"email"}},
+			{Field: "status", New: "draft"},
+		}
+		if got := diffCampaigns(Campaign{}, base); !reflect.DeepEqual(got, want) {
+			t.Errorf("diffCampaigns() = %+v, want %+v", got, want)
+		}
+	})
+}
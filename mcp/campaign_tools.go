@@ -0,0 +1,663 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// defaultCampaignPageSize is how many campaigns the query tools return when
+// no limit is given.
+const defaultCampaignPageSize = 20
+
+type ListCampaignsInput struct {
+	ClientName    string `json:"client_name,omitempty" description:"Only campaigns whose client name contains this (optional)"`
+	Status        string `json:"status,omitempty" description:"Only campaigns with this status (optional)" enum:"draft|active|paused|completed|archived"`
+	Channel       string `json:"channel,omitempty" description:"Only campaigns using this marketing channel (optional)"`
+	CreatedAfter  string `json:"created_after,omitempty" description:"Only campaigns created on or after this date, as YYYY-MM-DD or an RFC 3339 timestamp (optional)"`
+	CreatedBefore string `json:"created_before,omitempty" description:"Only campaigns created before this date, as YYYY-MM-DD or an RFC 3339 timestamp (optional)"`
+	Limit         int    `json:"limit,omitempty" description:"Campaigns per page (optional, defaults to 20)" minimum:"1" maximum:"100"`
+	Cursor        string `json:"cursor,omitempty" description:"next_cursor from the previous page, to fetch the page after it (optional)"`
+}
+
+type ListCampaignsOutput struct {
+	Campaigns  []Campaign `json:"campaigns" description:"Matching campaigns, newest first"`
+	NextCursor string     `json:"next_cursor,omitempty" description:"Pass as cursor to get the next page; absent on the last page"`
+}
+
+type GetCampaignInput struct {
+	ID string `json:"id" description:"Campaign ID, e.g. cmp_1a2b3c4d5e6f"`
+}
+
+type SearchCampaignsInput struct {
+	Query string `json:"query" description:"Words to look for in campaign names and descriptions"`
+	Limit int    `json:"limit,omitempty" description:"Maximum number of results (optional, defaults to 20)" minimum:"1" maximum:"100"`
+}
+
+type SearchCampaignsOutput struct {
+	Campaigns []Campaign `json:"campaigns" description:"Matching campaigns, best match first"`
+}
+
+type UpdateCampaignInput struct {
+	ID           string    `json:"id" description:"Campaign ID"`
+	Version      int       `json:"version" description:"The version you last saw; the update is refused if the campaign has changed since" minimum:"1"`
+	CampaignName string    `json:"campaign_name,omitempty" description:"New campaign name (optional)"`
+	ClientName   string    `json:"client_name,omitempty" description:"New client name (optional)"`
+	Description  string    `json:"description,omitempty" description:"New description (optional)"`
+	Budget       *float64  `json:"budget,omitempty" description:"New budget in dollars (optional)" minimum:"0"`
+	Channels     *[]string `json:"channels,omitempty" description:"New list of marketing channels, replacing the old one (optional)"`
+}
+
+type CampaignHistoryInput struct {
+	ID string `json:"id" description:"Campaign ID"`
+}
+
+type CampaignHistoryOutput struct {
+	CampaignID string          `json:"campaign_id" description:"Campaign ID"`
+	Events     []CampaignEvent `json:"events" description:"Every change to the campaign, oldest first"`
+}
+
+type DiffCampaignVersionsInput struct {
+	ID          string `json:"id" description:"Campaign ID"`
+	FromVersion int    `json:"from_version" description:"Older version to compare" minimum:"1"`
+	ToVersion   int    `json:"to_version,omitempty" description:"Newer version to compare (optional, defaults to the current version)" minimum:"1"`
+}
+
+type CampaignDiffOutput struct {
+	CampaignID  string        `json:"campaign_id" description:"Campaign ID"`
+	FromVersion int           `json:"from_version" description:"Older version compared"`
+	ToVersion   int           `json:"to_version" description:"Newer version compared"`
+	Changes     []FieldChange `json:"changes" description:"Fields that differ between the two versions"`
+}
+
+type ChangeCampaignStatusInput struct {
+	ID      string `json:"id" description:"Campaign ID"`
+	Action  string `json:"action" description:"activate (draft to active), pause (active to paused), resume (paused to active), complete (active or paused to completed) or archive (draft or completed to archived)" enum:"activate|pause|resume|complete|archive"`
+	Version int    `json:"version,omitempty" description:"The version you last saw; if given, the change is refused if the campaign has changed since (optional)" minimum:"1"`
+}
+
+func registerCampaignTools(r *ToolRegistry) {
+	readOnly := &ToolAnnotations{
+		ReadOnlyHint:   true,
+		IdempotentHint: true,
+	}
+
+	RegisterTool(r, Tool{
+		Name:         "list_campaigns",
+		Title:        "List Campaigns",
+		Description:  "List saved campaigns, newest first, optionally filtered by client, status, channel or creation date. Results are paginated: pass next_cursor back as cursor to get more.",
+		OutputSchema: schemaOf[ListCampaignsOutput](),
+		Annotations:  readOnly,
+	}, handleListCampaigns)
+
+	RegisterTool(r, Tool{
+		Name:         "get_campaign",
+		Title:        "Get Campaign",
+		Description:  "Get a saved campaign by its ID",
+		OutputSchema: schemaOf[Campaign](),
+		Annotations:  readOnly,
+	}, handleGetCampaign)
+
+	RegisterTool(r, Tool{
+		Name:         "search_campaigns",
+		Title:        "Search Campaigns",
+		Description:  "Search saved campaigns by words in their name or description",
+		OutputSchema: schemaOf[SearchCampaignsOutput](),
+		Annotations:  readOnly,
+	}, handleSearchCampaigns)
+
+	RegisterTool(r, Tool{
+		Name:         "get_campaign_history",
+		Title:        "Get Campaign History",
+		Description:  "Show every change made to a campaign: when, by which client, and the old and new values",
+		OutputSchema: schemaOf[CampaignHistoryOutput](),
+		Annotations:  readOnly,
+	}, handleGetCampaignHistory)
+
+	RegisterTool(r, Tool{
+		Name:         "diff_campaign_versions",
+		Title:        "Compare Campaign Versions",
+		Description:  "Compare two versions of a campaign and list the fields that differ",
+		OutputSchema: schemaOf[CampaignDiffOutput](),
+		Annotations:  readOnly,
+	}, handleDiffCampaignVersions)
+
+	RegisterTool(r, Tool{
+		Name:         "update_campaign",
+		Title:        "Update Campaign",
+		Description:  "Change some details of a saved campaign. Only the fields given are changed. Pass the version from the campaign as last seen; if someone else changed it since, the update is refused so their change is not overwritten.",
+		OutputSchema: schemaOf[Campaign](),
+		Annotations: &ToolAnnotations{
+			DestructiveHint: true,
+			OpenWorldHint:   true,
+		},
+	}, handleUpdateCampaign)
+
+	RegisterTool(r, Tool{
+		Name:         "change_campaign_status",
+		Title:        "Change Campaign Status",
+		Description:  "Move a campaign through its lifecycle: activate a draft, pause or resume it, mark it complete, or archive it",
+		OutputSchema: schemaOf[Campaign](),
+		Annotations: &ToolAnnotations{
+			DestructiveHint: true,
+			OpenWorldHint:   true,
+		},
+	}, handleChangeCampaignStatus)
+}
+
+func handleListCampaigns(ctx context.Context, input ListCampaignsInput) ToolResult {
+	filter := CampaignFilter{
+		ClientName: input.ClientName,
+		Status:     CampaignStatus(input.Status),
+		Channel:    input.Channel,
+	}
+
+	var err error
+	if filter.CreatedAfter, err = parseDate(input.CreatedAfter); err != nil {
+		return invalidInput("created_after", err)
+	}
+	if filter.CreatedBefore, err = parseDate(input.CreatedBefore); err != nil {
+		return invalidInput("created_before", err)
+	}
+
+	var after *campaignCursor
+	if input.Cursor != "" {
+		if after, err = decodeCampaignCursor(input.Cursor); err != nil {
+			return invalidInput("cursor", err)
+		}
+	}
+
+	store, err := campaigns()
+	if err != nil {
+		return campaignStoreError(err)
+	}
+	matched, err := store.List(ctx, filter)
+	if err != nil {
+		return campaignStoreError(err)
+	}
+
+	output, start := pageCampaigns(matched, after, intWithDefault(input.Limit, defaultCampaignPageSize))
+	end := start + len(output.Campaigns)
+
+	text := fmt.Sprintf("📋 **%d campaign(s)**", len(output.Campaigns))
+	if start > 0 || output.NextCursor != "" {
+		text = fmt.Sprintf("📋 **Campaigns %d–%d of %d**", start+1, end, len(matched))
+	}
+	text += "\n\n" + campaignLines(output.Campaigns)
+	if output.NextCursor != "" {
+		text += fmt.Sprintf("\n\nMore campaigns available, use cursor %q for the next page.", output.NextCursor)
+	}
+
+	return ToolResult{
+		Content: []Content{{
+			Type: "text",
+			Text: text,
+		}},
+		StructuredContent: output,
+	}
+}
+
+// pageCampaigns returns up to limit campaigns following the cursor, and the
+// index of the first one in matched. Campaigns are newest first, so the page
+// starts at the first one older than the cursor. Keying on the last campaign
+// seen rather than a count means campaigns created while paging do not shift
+// the pages.
+func pageCampaigns(matched []Campaign, after *campaignCursor, limit int) (ListCampaignsOutput, int) {
+	start := 0
+	if after != nil {
+		start = len(matched)
+		for i, c := range matched {
+			if newerFirst(after.campaign(), c) < 0 {
+				start = i
+				break
+			}
+		}
+	}
+
+	end := min(start+limit, len(matched))
+	output := ListCampaignsOutput{Campaigns: append([]Campaign{}, matched[start:end]...)}
+	if end < len(matched) {
+		output.NextCursor = encodeCampaignCursor(matched[end-1])
+	}
+	return output, start
+}
+
+func handleGetCampaign(ctx context.Context, input GetCampaignInput) ToolResult {
+	store, err := campaigns()
+	if err != nil {
+		return campaignStoreError(err)
+	}
+
+	campaign, err := store.Get(ctx, input.ID)
+	if errors.Is(err, ErrCampaignNotFound) {
+		return ToolResult{
+			Content: []Content{{
+				Type: "text",
+				Text: fmt.Sprintf("❌ No campaign with ID %s. Use list_campaigns or search_campaigns to find the right ID.", input.ID),
+			}},
+			IsError: true,
+		}
+	}
+	if err != nil {
+		return campaignStoreError(err)
+	}
+
+	return ToolResult{
+		Content: []Content{{
+			Type: "text",
+			Text: campaignDetails(campaign),
+		}},
+		StructuredContent: campaign,
+	}
+}
+
+func handleGetCampaignHistory(ctx context.Context, input CampaignHistoryInput) ToolResult {
+	store, err := campaigns()
+	if err != nil {
+		return campaignStoreError(err)
+	}
+	revisions, err := store.History(ctx, input.ID)
+	if err != nil {
+		return campaignError(input.ID, err)
+	}
+
+	output := CampaignHistoryOutput{CampaignID: input.ID}
+	lines := make([]string, len(revisions))
+	for i, revision := range revisions {
+		event := revision.Event
+		output.Events = append(output.Events, event)
+
+		line := fmt.Sprintf("• **v%d** %s, %s by %s", event.Version, event.At.Format("2006-01-02 15:04 MST"), strings.ReplaceAll(event.Type, "_", " "), event.Actor)
+		if len(event.Changes) > 0 {
+			line += "\n" + describeChanges(event.Changes, "    ")
+		}
+		lines[i] = line
+	}
+
+	current := revisions[len(revisions)-1].Campaign
+	return ToolResult{
+		Content: []Content{{
+			Type: "text",
+			Text: fmt.Sprintf("🕘 **History of %s** (%s)\n\n%s", current.CampaignName, current.ID, strings.Join(lines, "\n")),
+		}},
+		StructuredContent: output,
+	}
+}
+
+func handleDiffCampaignVersions(ctx context.Context, input DiffCampaignVersionsInput) ToolResult {
+	store, err := campaigns()
+	if err != nil {
+		return campaignStoreError(err)
+	}
+	revisions, err := store.History(ctx, input.ID)
+	if err != nil {
+		return campaignError(input.ID, err)
+	}
+
+	// Versions are numbered from 1 without gaps, but look them up rather
+	// than index so a damaged history fails cleanly
+	find := func(version int) (Campaign, bool) {
+		for _, revision := range revisions {
+			if revision.Campaign.Version == version {
+				return revision.Campaign, true
+			}
+		}
+		return Campaign{}, false
+	}
+
+	latest := revisions[len(revisions)-1].Campaign.Version
+	to := intWithDefault(input.ToVersion, latest)
+	from, ok := find(input.FromVersion)
+	if !ok {
+		return invalidInput("from_version", fmt.Errorf("campaign %s has versions 1 to %d", input.ID, latest))
+	}
+	target, ok := find(to)
+	if !ok {
+		return invalidInput("to_version", fmt.Errorf("campaign %s has versions 1 to %d", input.ID, latest))
+	}
+
+	output := CampaignDiffOutput{
+		CampaignID:  input.ID,
+		FromVersion: input.FromVersion,
+		ToVersion:   to,
+		Changes:     diffCampaigns(from, target),
+	}
+	if output.Changes == nil {
+		output.Changes = []FieldChange{}
+	}
+
+	text := fmt.Sprintf("🔀 **%s** (%s), version %d → %d\n\n", target.CampaignName, input.ID, input.FromVersion, to)
+	if len(output.Changes) == 0 {
+		text += "No differences."
+	} else {
+		text += describeChanges(output.Changes, "")
+	}
+
+	return ToolResult{
+		Content: []Content{{
+			Type: "text",
+			Text: text,
+		}},
+		StructuredContent: output,
+	}
+}
+
+// describeChanges lists field changes one per line.
+func describeChanges(changes []FieldChange, indent string) string {
+	lines := make([]string, len(changes))
+	for i, change := range changes {
+		lines[i] = fmt.Sprintf("%s%s: %s → %s", indent, strings.ReplaceAll(change.Field, "_", " "),
+			describeValue(change.Field, change.Old), describeValue(change.Field, change.New))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func describeValue(field string, value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "(none)"
+	case float64:
+		if field == "budget" {
+			return fmt.Sprintf("$%.2f", v)
+		}
+		return formatFloat(v)
+	case []string:
+		return strings.Join(v, ", ")
+	case []interface{}:
+		// Channels read back from the store
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = fmt.Sprint(item)
+		}
+		return strings.Join(items, ", ")
+	case string:
+		return fmt.Sprintf("%q", v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func handleUpdateCampaign(ctx context.Context, input UpdateCampaignInput) ToolResult {
+	store, err := campaigns()
+	if err != nil {
+		return campaignStoreError(err)
+	}
+
+	var changed []string
+	var rejected error
+	campaign, err := store.Update(ctx, input.ID, input.Version, func(c *Campaign) error {
+		if rejected = c.editable(); rejected != nil {
+			return rejected
+		}
+
+		if input.CampaignName != "" && input.CampaignName != c.CampaignName {
+			c.CampaignName = input.CampaignName
+			changed = append(changed, "name")
+		}
+		if input.ClientName != "" && input.ClientName != c.ClientName {
+			c.ClientName = input.ClientName
+			changed = append(changed, "client")
+		}
+		if input.Description != "" && input.Description != c.Description {
+			c.Description = input.Description
+			changed = append(changed, "description")
+		}
+		if input.Budget != nil && (c.Budget == nil || *input.Budget != *c.Budget) {
+			c.Budget = input.Budget
+			changed = append(changed, "budget")
+		}
+		if input.Channels != nil && !slices.Equal(*input.Channels, c.Channels) {
+			c.Channels = *input.Channels
+			changed = append(changed, "channels")
+		}
+
+		if len(changed) == 0 {
+			rejected = errors.New("nothing to change, give at least one field with a new value")
+		}
+		return rejected
+	})
+	if rejected != nil {
+		return changeRejected(rejected)
+	}
+	if err != nil {
+		return campaignError(input.ID, err)
+	}
+
+	return ToolResult{
+		Content: []Content{{
+			Type: "text",
+			Text: fmt.Sprintf("✅ Updated %s of campaign %s, now at version %d.\n\n%s",
+				strings.Join(changed, ", "), campaign.ID, campaign.Version, campaignDetails(campaign)),
+		}},
+		StructuredContent: campaign,
+	}
+}
+
+func handleChangeCampaignStatus(ctx context.Context, input ChangeCampaignStatusInput) ToolResult {
+	action, ok := findCampaignAction(input.Action)
+	if !ok {
+		return invalidInput("action", fmt.Errorf("unknown action %q", input.Action))
+	}
+
+	store, err := campaigns()
+	if err != nil {
+		return campaignStoreError(err)
+	}
+
+	var from CampaignStatus
+	var rejected error
+	campaign, err := store.Update(ctx, input.ID, input.Version, func(c *Campaign) error {
+		from = c.Status
+		rejected = action.apply(c)
+		return rejected
+	})
+	if rejected != nil {
+		return changeRejected(rejected)
+	}
+	if err != nil {
+		return campaignError(input.ID, err)
+	}
+
+	return ToolResult{
+		Content: []Content{{
+			Type: "text",
+			Text: fmt.Sprintf("✅ Campaign **%s** (%s) is now %s (was %s), version %d.",
+				campaign.CampaignName, campaign.ID, campaign.Status, from, campaign.Version),
+		}},
+		StructuredContent: campaign,
+	}
+}
+
+// changeRejected reports a change to a campaign that is not allowed, such as
+// an illegal status transition. Nothing was saved.
+func changeRejected(err error) ToolResult {
+	return ToolResult{
+		Content: []Content{{
+			Type: "text",
+			Text: fmt.Sprintf("❌ Not saved: %s.", err.Error()),
+		}},
+		IsError: true,
+	}
+}
+
+// campaignError explains why the store could not read or update a campaign.
+func campaignError(id string, err error) ToolResult {
+	var conflict *VersionConflictError
+	switch {
+	case errors.Is(err, ErrCampaignNotFound):
+		return ToolResult{
+			Content: []Content{{
+				Type: "text",
+				Text: fmt.Sprintf("❌ No campaign with ID %s. Use list_campaigns or search_campaigns to find the right ID.", id),
+			}},
+			IsError: true,
+		}
+	case errors.As(err, &conflict):
+		return ToolResult{
+			Content: []Content{{
+				Type: "text",
+				Text: fmt.Sprintf("❌ Campaign %s was changed by someone else since version %d. Nothing was saved. This is the campaign now; check your change still makes sense and retry with version %d.\n\n%s",
+					id, conflict.Expected, conflict.Current.Version, campaignDetails(conflict.Current)),
+			}},
+			IsError: true,
+		}
+	default:
+		return campaignStoreError(err)
+	}
+}
+
+func handleSearchCampaigns(ctx context.Context, input SearchCampaignsInput) ToolResult {
+	terms := strings.Fields(strings.ToLower(input.Query))
+	if len(terms) == 0 {
+		return invalidInput("query", errors.New("give at least one word to search for"))
+	}
+
+	store, err := campaigns()
+	if err != nil {
+		return campaignStoreError(err)
+	}
+	all, err := store.List(ctx, CampaignFilter{})
+	if err != nil {
+		return campaignStoreError(err)
+	}
+
+	type hit struct {
+		campaign Campaign
+		score    int
+	}
+	var hits []hit
+	for _, c := range all {
+		if score := searchScore(c, terms); score > 0 {
+			hits = append(hits, hit{c, score})
+		}
+	}
+	// Stable, so equal scores stay newest first
+	slices.SortStableFunc(hits, func(a, b hit) int { return b.score - a.score })
+
+	output := SearchCampaignsOutput{Campaigns: []Campaign{}}
+	for _, h := range hits[:min(len(hits), intWithDefault(input.Limit, defaultCampaignPageSize))] {
+		output.Campaigns = append(output.Campaigns, h.campaign)
+	}
+
+	text := fmt.Sprintf("🔍 **%d campaign(s) matching %q**", len(output.Campaigns), input.Query)
+	if len(output.Campaigns) < len(hits) {
+		text = fmt.Sprintf("🔍 **Top %d of %d campaigns matching %q**", len(output.Campaigns), len(hits), input.Query)
+	}
+	text += "\n\n" + campaignLines(output.Campaigns)
+
+	return ToolResult{
+		Content: []Content{{
+			Type: "text",
+			Text: text,
+		}},
+		StructuredContent: output,
+	}
+}
+
+// searchScore ranks a campaign against lower-cased search terms. Every term
+// has to appear in the name or description; a term in the name counts double.
+// Zero means no match.
+func searchScore(c Campaign, terms []string) int {
+	name := strings.ToLower(c.CampaignName)
+	description := strings.ToLower(c.Description)
+
+	score := 0
+	for _, term := range terms {
+		inName := strings.Count(name, term)
+		inDescription := strings.Count(description, term)
+		if inName+inDescription == 0 {
+			return 0
+		}
+		score += 2*inName + inDescription
+	}
+	return score
+}
+
+// campaignCursor marks the last campaign of a page.
+type campaignCursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        string    `json:"id"`
+}
+
+func (c *campaignCursor) campaign() Campaign {
+	return Campaign{ID: c.ID, CreatedAt: c.CreatedAt}
+}
+
+func encodeCampaignCursor(last Campaign) string {
+	data, _ := json.Marshal(campaignCursor{CreatedAt: last.CreatedAt, ID: last.ID})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCampaignCursor(cursor string) (*campaignCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errors.New("not a cursor returned by list_campaigns")
+	}
+	var c campaignCursor
+	if err := json.Unmarshal(data, &c); err != nil || c.ID == "" {
+		return nil, errors.New("not a cursor returned by list_campaigns")
+	}
+	return &c, nil
+}
+
+// parseDate accepts a calendar date or a full timestamp. Empty is the zero
+// time, meaning no bound.
+func parseDate(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("%q is not a date like 2025-01-31 or a timestamp like 2025-01-31T09:00:00Z", value)
+}
+
+// invalidInput reports an argument that passed the schema but still makes no
+// sense, so the model can correct it.
+func invalidInput(field string, err error) ToolResult {
+	return ToolResult{
+		Content: []Content{{
+			Type: "text",
+			Text: fmt.Sprintf("❌ Invalid %s: %s", field, err.Error()),
+		}},
+		IsError: true,
+	}
+}
+
+// campaignLines lists campaigns one per line.
+func campaignLines(list []Campaign) string {
+	if len(list) == 0 {
+		return "No campaigns found."
+	}
+
+	lines := make([]string, len(list))
+	for i, c := range list {
+		line := fmt.Sprintf("• **%s** (%s) for %s, %s", c.CampaignName, c.ID, c.ClientName, c.Status)
+		if c.Budget != nil {
+			line += fmt.Sprintf(", $%.2f", *c.Budget)
+		}
+		lines[i] = line + ", created " + c.CreatedAt.Format(time.DateOnly)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// campaignDetails describes one campaign in full.
+func campaignDetails(c Campaign) string {
+	text := fmt.Sprintf("📣 **%s**\n\n• ID: %s\n• Client: %s\n• Description: %s\n• Status: %s", c.CampaignName, c.ID, c.ClientName, c.Description, c.Status)
+	if c.Budget != nil {
+		text += fmt.Sprintf("\n• Budget: $%.2f", *c.Budget)
+	}
+	if len(c.Channels) > 0 {
+		text += fmt.Sprintf("\n• Channels: %s", strings.Join(c.Channels, ", "))
+	}
+	text += fmt.Sprintf("\n• Version: %d\n• Created: %s\n• Updated: %s", c.Version, c.CreatedAt.Format(time.RFC3339), c.UpdatedAt.Format(time.RFC3339))
+	return text
+}
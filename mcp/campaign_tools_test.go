@@ -0,0 +1,111 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestPageCampaigns(t *testing.T) {
+	at := func(minute int) time.Time {
+		return time.Date(2025, 3, 1, 9, minute, 0, 0, time.UTC)
+	}
+	// Newest first, as the store lists them; c and d were created in the
+	// same minute, so d comes first by ID
+	matched := []Campaign{
+		{ID: "cmp_e", CreatedAt: at(40)},
+		{ID: "cmp_d", CreatedAt: at(30)},
+		{ID: "cmp_c", CreatedAt: at(30)},
+		{ID: "cmp_b", CreatedAt: at(20)},
+		{ID: "cmp_a", CreatedAt: at(10)},
+	}
+	cursor := func(id string, minute int) *campaignCursor {
+		return &campaignCursor{ID: id, CreatedAt: at(minute)}
+	}
+
+	tests := []struct {
+		name      string
+		after     *campaignCursor
+		limit     int
+		want      []string
+		wantStart int
+		wantNext  string // ID the next cursor points at, "" for none
+	}{
+		{name: "first page", limit: 2, want: []string{"cmp_e", "cmp_d"}, wantNext: "cmp_d"},
+		{name: "page after a tie", after: cursor("cmp_d", 30), limit: 2, want: []string{"cmp_c", "cmp_b"}, wantStart: 2, wantNext: "cmp_b"},
+		{name: "last page", after: cursor("cmp_b", 20), limit: 2, want: []string{"cmp_a"}, wantStart: 4},
+		{name: "everything fits", limit: 5, want: []string{"cmp_e", "cmp_d", "cmp_c", "cmp_b", "cmp_a"}},
+		{name: "past the end", after: cursor("cmp_a", 10), limit: 2, want: []string{}, wantStart: 5},
+		// The campaign the cursor names has since stopped matching the
+		// filter; paging carries on from where it was
+		{name: "cursor campaign gone", after: cursor("cmp_x", 25), limit: 10, want: []string{"cmp_b", "cmp_a"}, wantStart: 3},
+		{name: "cursor between tied campaigns", after: cursor("cmp_cc", 30), limit: 1, want: []string{"cmp_c"}, wantStart: 2, wantNext: "cmp_c"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, start := pageCampaigns(matched, tt.after, tt.limit)
+
+			got := []string{}
+			for _, c := range output.Campaigns {
+				got = append(got, c.ID)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("page = %v, want %v", got, tt.want)
+			}
+			if start != tt.wantStart {
+				t.Errorf("start = %d, want %d", start, tt.wantStart)
+			}
+
+			if tt.wantNext == "" {
+				if output.NextCursor != "" {
+					t.Errorf("next cursor = %q, want none", output.NextCursor)
+				}
+				return
+			}
+			next, err := decodeCampaignCursor(output.NextCursor)
+			if err != nil {
+				t.Fatalf("next cursor %q does not decode: %v", output.NextCursor, err)
+			}
+			if next.ID != tt.wantNext {
+				t.Errorf("next cursor points at %s, want %s", next.ID, tt.wantNext)
+			}
+		})
+	}
+}
+
+func TestPageCampaignsSkipsNewerCampaigns(t *testing.T) {
+	first := []Campaign{
+		{ID: "cmp_b", CreatedAt: time.Date(2025, 3, 1, 9, 20, 0, 0, time.UTC)},
+		{ID: "cmp_a", CreatedAt: time.Date(2025, 3, 1, 9, 10, 0, 0, time.UTC)},
+	}
+	page, _ := pageCampaigns(first, nil, 1)
+	after, err := decodeCampaignCursor(page.NextCursor)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A campaign created while the client was reading the first page must
+	// neither show up on the second page nor push cmp_b onto it again
+	later := append([]Campaign{{ID: "cmp_z", CreatedAt: time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)}}, first...)
+	page, _ = pageCampaigns(later, after, 1)
+	if len(page.Campaigns) != 1 || page.Campaigns[0].ID != "cmp_a" {
+		t.Errorf("second page = %+v, want only cmp_a", page.Campaigns)
+	}
+}
+
+func TestDecodeCampaignCursor(t *testing.T) {
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{"not base64", "not base64!"},
+		{"not JSON", "bm90IGpzb24"},
+		{"no ID", "e30"},
+	}
+	for _, tt := range tests {
+		if _, err := decodeCampaignCursor(tt.cursor); err == nil {
+			t.Errorf("%s: decodeCampaignCursor(%q) succeeded, want an error", tt.name, tt.cursor)
+		}
+	}
+}
@@ -0,0 +1,344 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// configPollInterval is how often the config file is checked for changes.
+const configPollInterval = 2 * time.Second
+
+// Config is the optional rave config file. It can supply the API key instead
+// of RAVE_API_KEY, turn on read-only mode, hide built-in tools, and add tools
+// that forward their arguments to an HTTP endpoint:
+//
+//	{
+//	  "api_key": "1234",
+//	  "read_only": true,
+//	  "disabled_tools": ["start_campaign_creation"],
+//	  "tools": [{
+//	    "name": "lookup_npi",
+//	    "description": "Look up a physician by NPI number",
+//	    "url": "https://example.com/npi",
+//	    "method": "GET",
+//	    "input_schema": {"type": "object", "properties": {"npi": {"type": "string"}}}
+//	  }]
+//	}
+type Config struct {
+	APIKey        string       `json:"api_key,omitempty"`
+	ReadOnly      bool         `json:"read_only,omitempty"`
+	DisabledTools []string     `json:"disabled_tools,omitempty"`
+	Tools         []ConfigTool `json:"tools,omitempty"`
+}
+
+// ConfigTool is a tool defined in the config file. GET tools send their
+// arguments as query parameters, anything else POSTs them as JSON. The
+// response body is returned to the model as text. Tools without annotations
+// are treated as making changes.
+type ConfigTool struct {
+	Name        string           `json:"name"`
+	Title       string           `json:"title,omitempty"`
+	Description string           `json:"description"`
+	URL         string           `json:"url"`
+	Method      string           `json:"method,omitempty"`
+	InputSchema *JSONSchema      `json:"input_schema,omitempty"`
+	Annotations *ToolAnnotations `json:"annotations,omitempty"`
+}
+
+// apiKeyTools only appear once a valid API key is configured.
+var apiKeyTools = map[string]bool{"create_list": true}
+
+var (
+	configMu      sync.RWMutex
+	currentConfig Config
+)
+
+// defaultConfigPath is config.json in the user's rave config directory, or ""
+// if that directory cannot be determined.
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "rave", "config.json")
+}
+
+// currentAPIKey prefers the config file over the environment.
+func currentAPIKey() string {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	if currentConfig.APIKey != "" {
+		return currentConfig.APIKey
+	}
+	return os.Getenv("RAVE_API_KEY")
+}
+
+func validAPIKey(key string) bool {
+	return key == "1234"
+}
+
+// readOnlyMode reports whether tools that make changes are switched off,
+// either by the config file or by setting RAVE_READ_ONLY.
+func readOnlyMode() bool {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	if currentConfig.ReadOnly {
+		return true
+	}
+	readOnly, _ := strconv.ParseBool(os.Getenv("RAVE_READ_ONLY"))
+	return readOnly
+}
+
+// loadConfig reads the config file. A missing file is an empty config.
+func loadConfig(path string) (Config, error) {
+	var cfg Config
+	if path == "" {
+		return cfg, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, err
+	}
+
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("invalid JSON in %s: %w", path, err)
+	}
+
+	for i, tool := range cfg.Tools {
+		if tool.Name == "" || tool.URL == "" {
+			return cfg, fmt.Errorf("tool #%d in %s needs both a name and a url", i+1, path)
+		}
+	}
+	return cfg, nil
+}
+
+// applyConfig makes the tool registry match cfg. Sessions are told about the
+// change, if the visible tool list actually changed.
+func applyConfig(cfg Config) {
+	configMu.Lock()
+	currentConfig = cfg
+	configMu.Unlock()
+
+	hasKey := validAPIKey(currentAPIKey())
+	readOnly := readOnlyMode()
+	disabled := make(map[string]bool, len(cfg.DisabledTools))
+	for _, name := range cfg.DisabledTools {
+		disabled[name] = true
+	}
+
+	tools.update(func() {
+		tools.removeSource(sourceConfig)
+
+		for _, tool := range cfg.Tools {
+			// Built-in tools cannot be replaced, as a later reload would
+			// drop the config tool and leave neither
+			if existing, ok := tools.lookup(tool.Name); ok && existing.source == sourceBuiltin {
+				fmt.Fprintf(os.Stderr, "Skipping config tool %s: a built-in tool has that name\n", tool.Name)
+				continue
+			}
+			tools.add(configToolEntry(tool))
+		}
+
+		for _, tool := range tools.all() {
+			var reason string
+			switch {
+			case disabled[tool.Name]:
+				reason = "it is disabled in the server config"
+			case readOnly && tool.writes():
+				reason = "the server is in read-only mode"
+			case apiKeyTools[tool.Name] && !hasKey:
+				reason = "no valid API key is configured"
+			}
+			tools.setUnavailable(tool.Name, reason)
+		}
+	})
+}
+
+// watchConfig loads the config file and reloads it whenever it changes until
+// ctx is done. A config that fails to load leaves the previous one in place.
+func watchConfig(ctx context.Context, path string) {
+	reload := func() {
+		cfg, err := loadConfig(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading config, keeping previous settings: %s\n", err)
+			return
+		}
+		applyConfig(cfg)
+	}
+
+	reload()
+	if path == "" {
+		return
+	}
+
+	go watchFile(ctx, path, configPollInterval, func() {
+		fmt.Fprintf(os.Stderr, "Config file %s changed, reloading\n", path)
+		reload()
+	})
+}
+
+// watchFile polls path and calls onChange whenever its modification time or
+// size changes, including when it is created or removed. For a directory, a
+// change to any file directly inside it counts too.
+func watchFile(ctx context.Context, path string, interval time.Duration, onChange func()) {
+	stamp := func() string {
+		info, err := os.Stat(path)
+		if err != nil {
+			return ""
+		}
+		s := fmt.Sprintf("%d:%d", info.ModTime().UnixNano(), info.Size())
+		if info.IsDir() {
+			entries, _ := os.ReadDir(path)
+			for _, entry := range entries {
+				if info, err := entry.Info(); err == nil {
+					s += fmt.Sprintf(" %s:%d:%d", entry.Name(), info.ModTime().UnixNano(), info.Size())
+				}
+			}
+		}
+		return s
+	}
+
+	last := stamp()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if current := stamp(); current != last {
+				last = current
+				onChange()
+			}
+		}
+	}
+}
+
+func configToolEntry(tool ConfigTool) *registeredTool {
+	schema := tool.InputSchema
+	if schema == nil {
+		schema = &JSONSchema{Type: "object"}
+	}
+
+	return &registeredTool{
+		tool: Tool{
+			Name:        tool.Name,
+			Title:       tool.Title,
+			Description: tool.Description,
+			InputSchema: schema,
+			Annotations: tool.Annotations,
+		},
+		handler: func(ctx context.Context, arguments json.RawMessage) ToolResult {
+			return callConfigTool(ctx, tool, arguments)
+		},
+		source: sourceConfig,
+	}
+}
+
+func callConfigTool(ctx context.Context, tool ConfigTool, arguments json.RawMessage) ToolResult {
+	if len(arguments) == 0 {
+		arguments = json.RawMessage("{}")
+	}
+
+	var req *http.Request
+	var err error
+	if tool.Method == "" || tool.Method == http.MethodPost {
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost, tool.URL, bytes.NewReader(arguments))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+	} else {
+		req, err = newQueryRequest(ctx, tool.Method, tool.URL, arguments)
+	}
+	if err != nil {
+		return ToolResult{
+			Content: []Content{{
+				Type: "text",
+				Text: fmt.Sprintf("❌ Error building request for %s: %s", tool.Name, err.Error()),
+			}},
+			IsError: true,
+		}
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return ToolResult{
+			Content: []Content{{
+				Type: "text",
+				Text: fmt.Sprintf("❌ Network error calling %s: %s", tool.Name, err.Error()),
+			}},
+			IsError: true,
+		}
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return ToolResult{
+			Content: []Content{{
+				Type: "text",
+				Text: fmt.Sprintf("❌ Error reading response from %s: %s", tool.Name, err.Error()),
+			}},
+			IsError: true,
+		}
+	}
+
+	if resp.StatusCode >= 300 {
+		return ToolResult{
+			Content: []Content{{
+				Type: "text",
+				Text: fmt.Sprintf("❌ %s returned %s: %s", tool.Name, resp.Status, body),
+			}},
+			IsError: true,
+		}
+	}
+
+	return ToolResult{
+		Content: []Content{{
+			Type: "text",
+			Text: string(body),
+		}},
+	}
+}
+
+// newQueryRequest builds a request carrying the top-level arguments as query
+// parameters. Non-string values are sent as their JSON text.
+func newQueryRequest(ctx context.Context, method, rawURL string, arguments json.RawMessage) (*http.Request, error) {
+	var args map[string]json.RawMessage
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return nil, err
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	query := u.Query()
+	for name, raw := range args {
+		var s string
+		if json.Unmarshal(raw, &s) != nil {
+			s = string(raw)
+		}
+		query.Set(name, s)
+	}
+	u.RawQuery = query.Encode()
+
+	return http.NewRequestWithContext(ctx, method, u.String(), nil)
+}
@@ -0,0 +1,44 @@
+package main
+
+import "encoding/json"
+
+// Content is one block of a tool result. Type selects which of the other
+// fields are used:
+//
+//	text           Text
+//	image          Data (base64) and MimeType
+//	resource_link  URI, Name, and optionally Description and MimeType
+//	resource       Resource, the contents embedded in the result
+type Content struct {
+	Type        string            `json:"type"`
+	Text        string            `json:"text,omitempty"`
+	Data        string            `json:"data,omitempty"`
+	MimeType    string            `json:"mimeType,omitempty"`
+	URI         string            `json:"uri,omitempty"`
+	Name        string            `json:"name,omitempty"`
+	Description string            `json:"description,omitempty"`
+	Resource    *ResourceContents `json:"resource,omitempty"`
+}
+
+// ResourceContents is the contents of a resource, either as Text or as
+// base64-encoded Blob.
+type ResourceContents struct {
+	URI      string `json:"uri"`
+	MimeType string `json:"mimeType,omitempty"`
+	Text     string `json:"text,omitempty"`
+	Blob     string `json:"blob,omitempty"`
+}
+
+// MarshalJSON keeps the text of a text block even when it is empty, since
+// clients expect the field to be there.
+func (c Content) MarshalJSON() ([]byte, error) {
+	if c.Type == "text" {
+		return json.Marshal(struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		}{c.Type, c.Text})
+	}
+
+	type plain Content
+	return json.Marshal(plain(c))
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// defaultDraftTTL is how long an untouched draft is kept when RAVE_DRAFT_TTL
+// is not set.
+const defaultDraftTTL = 24 * time.Hour
+
+// ErrDraftNotFound is returned for a draft that never existed or expired.
+var ErrDraftNotFound = errors.New("draft not found")
+
+// ErrDraftCommitted is returned when changing a draft that has already been
+// turned into a campaign.
+var ErrDraftCommitted = errors.New("draft already committed")
+
+// CampaignDraft is a campaign being filled in one field at a time by the
+// creation wizard. Its fields only become a Campaign on commit. CampaignID is
+// set as the commit starts, before the campaign is created, so committing
+// again finds that campaign rather than making a second one. Committed
+// drafts are kept until they expire for the same reason.
+type CampaignDraft struct {
+	ID         string    `json:"id"`
+	Fields     Campaign  `json:"fields"`
+	CampaignID string    `json:"campaign_id,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// DraftStore keeps wizard drafts in a JSON file so that they survive a
+// restart. Drafts are small and short-lived, so unlike campaigns the whole
+// file is rewritten on every change, atomically through a rename. It is read
+// again before each operation because other copies of the server may share
+// it, and changes hold a lock on it until the rewrite is done so that no copy
+// overwrites another's. Drafts expire once they have gone ttl without a
+// change.
+type DraftStore struct {
+	path string
+	ttl  time.Duration
+
+	mu sync.Mutex
+}
+
+func OpenDraftStore(path string, ttl time.Duration) (*DraftStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("creating draft store directory: %w", err)
+	}
+	return &DraftStore{path: path, ttl: ttl}, nil
+}
+
+// Create starts an empty draft.
+func (s *DraftStore) Create() (CampaignDraft, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	unlock, err := lockFile(s.path)
+	if err != nil {
+		return CampaignDraft{}, err
+	}
+	defer unlock()
+
+	drafts, err := s.load()
+	if err != nil {
+		return CampaignDraft{}, err
+	}
+
+	now := time.Now().UTC()
+	draft := CampaignDraft{
+		ID:        newDraftID(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		ExpiresAt: now.Add(s.ttl),
+	}
+	drafts[draft.ID] = draft
+	return draft, s.save(drafts)
+}
+
+// Get returns a draft, or ErrDraftNotFound.
+func (s *DraftStore) Get(id string) (CampaignDraft, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	drafts, err := s.load()
+	if err != nil {
+		return CampaignDraft{}, err
+	}
+	draft, ok := drafts[id]
+	if !ok {
+		return CampaignDraft{}, ErrDraftNotFound
+	}
+	return draft, nil
+}
+
+// Update applies change to a draft and saves it, extending its expiry. An
+// error from change aborts the update and is returned as is.
+func (s *DraftStore) Update(id string, change func(*CampaignDraft) error) (CampaignDraft, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	unlock, err := lockFile(s.path)
+	if err != nil {
+		return CampaignDraft{}, err
+	}
+	defer unlock()
+
+	drafts, err := s.load()
+	if err != nil {
+		return CampaignDraft{}, err
+	}
+	draft, ok := drafts[id]
+	if !ok {
+		return CampaignDraft{}, ErrDraftNotFound
+	}
+
+	if err := change(&draft); err != nil {
+		return CampaignDraft{}, err
+	}
+	draft.UpdatedAt = time.Now().UTC()
+	draft.ExpiresAt = draft.UpdatedAt.Add(s.ttl)
+
+	drafts[id] = draft
+	return draft, s.save(drafts)
+}
+
+// load reads every draft that has not expired. Callers hold s.mu.
+func (s *DraftStore) load() (map[string]CampaignDraft, error) {
+	drafts := make(map[string]CampaignDraft)
+
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return drafts, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading drafts: %w", err)
+	}
+	if err := json.Unmarshal(data, &drafts); err != nil {
+		// Losing half-finished drafts beats refusing to start new ones
+		fmt.Fprintf(os.Stderr, "Discarding unreadable drafts in %s: %s\n", s.path, err)
+		return make(map[string]CampaignDraft), nil
+	}
+
+	now := time.Now()
+	for id, draft := range drafts {
+		if now.After(draft.ExpiresAt) {
+			delete(drafts, id)
+		}
+	}
+	return drafts, nil
+}
+
+// save replaces the file with drafts. Callers hold s.mu.
+func (s *DraftStore) save(drafts map[string]CampaignDraft) error {
+	data, err := json.MarshalIndent(drafts, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".drafts-*.json")
+	if err != nil {
+		return fmt.Errorf("saving drafts: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("saving drafts: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("saving drafts: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("saving drafts: %w", err)
+	}
+	return nil
+}
+
+func newDraftID() string {
+	b := make([]byte, 6)
+	rand.Read(b)
+	return "draft_" + hex.EncodeToString(b)
+}
+
+// draftTTL reads RAVE_DRAFT_TTL, a Go duration such as "48h" or "90m".
+func draftTTL() time.Duration {
+	value := os.Getenv("RAVE_DRAFT_TTL")
+	if value == "" {
+		return defaultDraftTTL
+	}
+	ttl, err := time.ParseDuration(value)
+	if err != nil || ttl <= 0 {
+		fmt.Fprintf(os.Stderr, "Ignoring invalid RAVE_DRAFT_TTL %q, drafts expire after %s\n", value, defaultDraftTTL)
+		return defaultDraftTTL
+	}
+	return ttl
+}
+
+var (
+	draftsOnce sync.Once
+	draftStore *DraftStore
+	draftsErr  error
+)
+
+// drafts returns the shared draft store, opening it on first use.
+func drafts() (*DraftStore, error) {
+	draftsOnce.Do(func() {
+		dir, err := dataDir()
+		if err != nil {
+			draftsErr = err
+			return
+		}
+		draftStore, draftsErr = OpenDraftStore(filepath.Join(dir, "drafts.json"), draftTTL())
+	})
+	return draftStore, draftsErr
+}
@@ -0,0 +1,472 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type CampaignDraftSetFieldInput struct {
+	DraftID string      `json:"draft_id" description:"Draft ID from start_campaign_creation"`
+	Field   string      `json:"field" description:"Field to set" enum:"campaign_name|client_name|description|budget|channels"`
+	Value   interface{} `json:"value" description:"New value: text for campaign_name, client_name and description, a dollar amount for budget, a list of channels (or comma-separated text) for channels. An empty value clears budget or channels."`
+}
+
+type CampaignDraftInput struct {
+	DraftID string `json:"draft_id" description:"Draft ID from start_campaign_creation"`
+}
+
+type CampaignDraftOutput struct {
+	DraftID      string    `json:"draft_id" description:"Draft ID to pass to the other campaign_draft tools"`
+	CampaignName string    `json:"campaign_name,omitempty" description:"Campaign name, if set"`
+	ClientName   string    `json:"client_name,omitempty" description:"Client name, if set"`
+	Description  string    `json:"description,omitempty" description:"Description, if set"`
+	Budget       *float64  `json:"budget,omitempty" description:"Budget in dollars, if set"`
+	Channels     []string  `json:"channels,omitempty" description:"Marketing channels, if set"`
+	Missing      []string  `json:"missing" description:"Required fields that still need a value"`
+	Complete     bool      `json:"complete" description:"Whether the draft can be committed"`
+	ExpiresAt    time.Time `json:"expires_at" description:"When the draft is discarded unless changed again"`
+	CampaignID   string    `json:"campaign_id,omitempty" description:"ID of the campaign the draft was committed as, once it has been"`
+}
+
+// draftField is one field the wizard asks for, with the question to ask and
+// how to check and store an answer.
+type draftField struct {
+	name     string
+	question string
+	required bool
+	isSet    func(c Campaign) bool
+	set      func(c *Campaign, value interface{}) error
+}
+
+var draftFields = []draftField{
+	{
+		name:     "campaign_name",
+		question: "What would you like to call this campaign?",
+		required: true,
+		isSet:    func(c Campaign) bool { return c.CampaignName != "" },
+		set: func(c *Campaign, value interface{}) (err error) {
+			c.CampaignName, err = draftText(value, 100)
+			return err
+		},
+	},
+	{
+		name:     "client_name",
+		question: "Which client is this campaign for?",
+		required: true,
+		isSet:    func(c Campaign) bool { return c.ClientName != "" },
+		set: func(c *Campaign, value interface{}) (err error) {
+			c.ClientName, err = draftText(value, 100)
+			return err
+		},
+	},
+	{
+		name:     "description",
+		question: "Can you describe what this campaign is about?",
+		required: true,
+		isSet:    func(c Campaign) bool { return c.Description != "" },
+		set: func(c *Campaign, value interface{}) (err error) {
+			c.Description, err = draftText(value, 2000)
+			return err
+		},
+	},
+	{
+		name:     "budget",
+		question: "What's the budget for this campaign?",
+		isSet:    func(c Campaign) bool { return c.Budget != nil },
+		set: func(c *Campaign, value interface{}) (err error) {
+			c.Budget, err = draftBudget(value)
+			return err
+		},
+	},
+	{
+		name:     "channels",
+		question: "Which marketing channels do you want to use? (e.g., email, social, google-ads, facebook-ads)",
+		isSet:    func(c Campaign) bool { return len(c.Channels) > 0 },
+		set: func(c *Campaign, value interface{}) (err error) {
+			c.Channels, err = draftChannels(value)
+			return err
+		},
+	},
+}
+
+func findDraftField(name string) (draftField, bool) {
+	for _, field := range draftFields {
+		if field.name == name {
+			return field, true
+		}
+	}
+	return draftField{}, false
+}
+
+func registerDraftTools(r *ToolRegistry) {
+	RegisterTool(r, Tool{
+		Name:         "campaign_draft_set_field",
+		Title:        "Set Campaign Draft Field",
+		Description:  "Set one field of a campaign draft started with start_campaign_creation. The value is checked straight away, so ask the user again if it is rejected.",
+		OutputSchema: schemaOf[CampaignDraftOutput](),
+		Annotations: &ToolAnnotations{
+			IdempotentHint: true,
+		},
+	}, handleCampaignDraftSetField)
+
+	RegisterTool(r, Tool{
+		Name:         "campaign_draft_status",
+		Title:        "Campaign Draft Status",
+		Description:  "Show what a campaign draft has so far and which required fields are still missing",
+		OutputSchema: schemaOf[CampaignDraftOutput](),
+		Annotations: &ToolAnnotations{
+			ReadOnlyHint:   true,
+			IdempotentHint: true,
+		},
+	}, handleCampaignDraftStatus)
+
+	RegisterTool(r, Tool{
+		Name:         "campaign_draft_commit",
+		Title:        "Create Campaign From Draft",
+		Description:  "Create the campaign from a completed draft. Fails, listing what is missing, if any required field is not set yet.",
+		OutputSchema: schemaOf[Campaign](),
+		Annotations: &ToolAnnotations{
+			DestructiveHint: true,
+			OpenWorldHint:   true,
+		},
+	}, handleCampaignDraftCommit)
+}
+
+func handleStartCampaignCreation(ctx context.Context, input StartCampaignCreationInput) ToolResult {
+	store, err := drafts()
+	if err != nil {
+		return draftStoreError(err)
+	}
+	draft, err := store.Create()
+	if err != nil {
+		return draftStoreError(err)
+	}
+
+	var required, optional []string
+	for _, field := range draftFields {
+		line := fmt.Sprintf("- **%s**: %s", field.name, field.question)
+		if field.required {
+			required = append(required, line)
+		} else {
+			optional = append(optional, line)
+		}
+	}
+
+	text := fmt.Sprintf("🚀 Let's create a new campaign! Draft **%s** is ready.\n\nI need some information from you:\n\n%s\n\nOptionally, you can also provide:\n%s\n\nSave each answer with campaign_draft_set_field as you get it, then call campaign_draft_commit to create the campaign. The draft is kept for %s after its last change.",
+		draft.ID, strings.Join(required, "\n"), strings.Join(optional, "\n"), formatTTL(store.ttl))
+
+	return ToolResult{
+		Content: []Content{{
+			Type: "text",
+			Text: text,
+		}},
+		StructuredContent: draftOutput(draft),
+	}
+}
+
+func handleCampaignDraftSetField(ctx context.Context, input CampaignDraftSetFieldInput) ToolResult {
+	field, ok := findDraftField(input.Field)
+	if !ok {
+		return invalidInput("field", fmt.Errorf("unknown field %q", input.Field))
+	}
+
+	store, err := drafts()
+	if err != nil {
+		return draftStoreError(err)
+	}
+
+	var rejected error
+	draft, err := store.Update(input.DraftID, func(d *CampaignDraft) error {
+		if d.CampaignID != "" {
+			return ErrDraftCommitted
+		}
+		rejected = field.set(&d.Fields, input.Value)
+		return rejected
+	})
+	if rejected != nil {
+		return ToolResult{
+			Content: []Content{{
+				Type: "text",
+				Text: fmt.Sprintf("❌ That %s was not accepted: %s. Please ask the user: %s", input.Field, rejected.Error(), field.question),
+			}},
+			IsError: true,
+		}
+	}
+	if err != nil {
+		return draftError(input.DraftID, err)
+	}
+
+	return ToolResult{
+		Content: []Content{{
+			Type: "text",
+			Text: fmt.Sprintf("✅ Saved %s.\n\n%s", input.Field, draftSummary(draft)),
+		}},
+		StructuredContent: draftOutput(draft),
+	}
+}
+
+func handleCampaignDraftStatus(ctx context.Context, input CampaignDraftInput) ToolResult {
+	store, err := drafts()
+	if err != nil {
+		return draftStoreError(err)
+	}
+	draft, err := store.Get(input.DraftID)
+	if err != nil {
+		return draftError(input.DraftID, err)
+	}
+
+	return ToolResult{
+		Content: []Content{{
+			Type: "text",
+			Text: draftSummary(draft),
+		}},
+		StructuredContent: draftOutput(draft),
+	}
+}
+
+func handleCampaignDraftCommit(ctx context.Context, input CampaignDraftInput) ToolResult {
+	store, err := drafts()
+	if err != nil {
+		return draftStoreError(err)
+	}
+	draft, err := store.Get(input.DraftID)
+	if err != nil {
+		return draftError(input.DraftID, err)
+	}
+
+	if missing := missingDraftFields(draft.Fields); len(missing) > 0 {
+		return ToolResult{
+			Content: []Content{{
+				Type: "text",
+				Text: fmt.Sprintf("❌ The draft is not complete yet.\n\n%s", draftSummary(draft)),
+			}},
+			IsError: true,
+		}
+	}
+
+	campaignStore, err := campaigns()
+	if err != nil {
+		return campaignStoreError(err)
+	}
+	// The campaign's ID is recorded before it is created, so a commit that
+	// is retried or runs twice at once creates it only the first time
+	draft, err = store.Update(draft.ID, func(d *CampaignDraft) error {
+		if d.CampaignID == "" {
+			d.CampaignID = newCampaignID()
+		}
+		return nil
+	})
+	if err != nil {
+		return draftError(input.DraftID, err)
+	}
+
+	fields := draft.Fields
+	fields.ID = draft.CampaignID
+	campaign, err := campaignStore.Create(ctx, fields)
+	if errors.Is(err, ErrCampaignExists) {
+		campaign, err = campaignStore.Get(ctx, draft.CampaignID)
+	}
+	if err != nil {
+		// Unless a concurrent commit got the campaign created after all, the
+		// reservation is given back so the draft can be fixed and committed
+		if existing, getErr := campaignStore.Get(ctx, draft.CampaignID); getErr == nil {
+			return campaignCreatedResult(existing)
+		}
+		reserved := draft.CampaignID
+		store.Update(draft.ID, func(d *CampaignDraft) error {
+			if d.CampaignID == reserved {
+				d.CampaignID = ""
+			}
+			return nil
+		})
+		return campaignStoreError(err)
+	}
+	return campaignCreatedResult(campaign)
+}
+
+// draftSummary lists a draft's fields and what is still needed.
+func draftSummary(draft CampaignDraft) string {
+	lines := []string{fmt.Sprintf("📝 **Draft %s**", draft.ID), ""}
+	for _, field := range draftFields {
+		value := "(not set)"
+		if field.isSet(draft.Fields) {
+			value = draftFieldValue(draft.Fields, field.name)
+		} else if field.required {
+			value = "(required, not set)"
+		}
+		lines = append(lines, fmt.Sprintf("• %s: %s", field.name, value))
+	}
+
+	missing := missingDraftFields(draft.Fields)
+	if draft.CampaignID != "" {
+		lines = append(lines, "", fmt.Sprintf("Committed as campaign %s. Use update_campaign to change it.", draft.CampaignID))
+	} else if len(missing) == 0 {
+		lines = append(lines, "", "All required fields are set. Call campaign_draft_commit to create the campaign.")
+	} else {
+		lines = append(lines, "", "Still needed, please ask the user:")
+		for _, name := range missing {
+			field, _ := findDraftField(name)
+			lines = append(lines, fmt.Sprintf("- **%s**: %s", name, field.question))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func draftFieldValue(c Campaign, name string) string {
+	switch name {
+	case "campaign_name":
+		return c.CampaignName
+	case "client_name":
+		return c.ClientName
+	case "description":
+		return c.Description
+	case "budget":
+		return fmt.Sprintf("$%.2f", *c.Budget)
+	case "channels":
+		return strings.Join(c.Channels, ", ")
+	}
+	return ""
+}
+
+func missingDraftFields(c Campaign) []string {
+	missing := []string{}
+	for _, field := range draftFields {
+		if field.required && !field.isSet(c) {
+			missing = append(missing, field.name)
+		}
+	}
+	return missing
+}
+
+func draftOutput(draft CampaignDraft) CampaignDraftOutput {
+	missing := missingDraftFields(draft.Fields)
+	return CampaignDraftOutput{
+		DraftID:      draft.ID,
+		CampaignName: draft.Fields.CampaignName,
+		ClientName:   draft.Fields.ClientName,
+		Description:  draft.Fields.Description,
+		Budget:       draft.Fields.Budget,
+		Channels:     draft.Fields.Channels,
+		Missing:      missing,
+		Complete:     len(missing) == 0,
+		ExpiresAt:    draft.ExpiresAt,
+		CampaignID:   draft.CampaignID,
+	}
+}
+
+func draftText(value interface{}, maxLength int) (string, error) {
+	s, ok := value.(string)
+	if !ok {
+		return "", errors.New("expected text")
+	}
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return "", errors.New("it cannot be empty")
+	}
+	if len([]rune(s)) > maxLength {
+		return "", fmt.Errorf("it must be at most %d characters", maxLength)
+	}
+	return s, nil
+}
+
+// draftBudget accepts a number or text like "$5,000". Empty text clears the
+// budget.
+func draftBudget(value interface{}) (*float64, error) {
+	var budget float64
+	switch v := value.(type) {
+	case float64:
+		budget = v
+	case string:
+		if strings.TrimSpace(v) == "" {
+			return nil, nil
+		}
+		cleaned := strings.NewReplacer("$", "", ",", "", " ", "").Replace(v)
+		f, err := strconv.ParseFloat(cleaned, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a dollar amount", v)
+		}
+		budget = f
+	default:
+		return nil, errors.New("expected a dollar amount")
+	}
+
+	if budget < 0 {
+		return nil, errors.New("it cannot be negative")
+	}
+	return &budget, nil
+}
+
+// draftChannels accepts a list or comma-separated text. Channels are
+// lower-cased and duplicates dropped.
+func draftChannels(value interface{}) ([]string, error) {
+	var items []string
+	switch v := value.(type) {
+	case string:
+		items = strings.Split(v, ",")
+	case []interface{}:
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, errors.New("expected a list of channel names")
+			}
+			items = append(items, s)
+		}
+	default:
+		return nil, errors.New("expected a list of channel names")
+	}
+
+	var channels []string
+	for _, item := range items {
+		channel := strings.ToLower(strings.TrimSpace(item))
+		if channel != "" && !containsString(channels, channel) {
+			channels = append(channels, channel)
+		}
+	}
+	return channels, nil
+}
+
+func formatTTL(ttl time.Duration) string {
+	if ttl%time.Hour == 0 {
+		return fmt.Sprintf("%d hours", int(ttl.Hours()))
+	}
+	return ttl.String()
+}
+
+// draftError explains why a draft could not be read or changed.
+func draftError(id string, err error) ToolResult {
+	if errors.Is(err, ErrDraftCommitted) {
+		return ToolResult{
+			Content: []Content{{
+				Type: "text",
+				Text: fmt.Sprintf("❌ Draft %s has already been committed. Use update_campaign to change the campaign.", id),
+			}},
+			IsError: true,
+		}
+	}
+	if errors.Is(err, ErrDraftNotFound) {
+		return ToolResult{
+			Content: []Content{{
+				Type: "text",
+				Text: fmt.Sprintf("❌ No draft with ID %s. It may have expired; start a new one with start_campaign_creation.", id),
+			}},
+			IsError: true,
+		}
+	}
+	return draftStoreError(err)
+}
+
+func draftStoreError(err error) ToolResult {
+	fmt.Fprintf(os.Stderr, "Draft store error: %s\n", err)
+	return ToolResult{
+		Content: []Content{{
+			Type: "text",
+			Text: fmt.Sprintf("❌ Could not access campaign drafts: %s", err.Error()),
+		}},
+		IsError: true,
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// elicitationTimeout bounds how long a tool waits for the user to answer a
+// form before carrying on without it.
+const elicitationTimeout = 10 * time.Minute
+
+type elicitationKey struct{}
+
+// Elicitor asks the user for input through the client with
+// elicitation/create. Only clients that declared the elicitation capability
+// get one; a nil Elicitor is valid and never asks.
+type Elicitor struct {
+	ctx     context.Context
+	session *Session
+}
+
+// ElicitParams is an elicitation/create request. The requested schema must be
+// a flat object of strings, numbers, booleans and enums.
+type ElicitParams struct {
+	Message         string      `json:"message"`
+	RequestedSchema *JSONSchema `json:"requestedSchema"`
+}
+
+// ElicitResult is the user's answer. Content is only set when Action is
+// "accept"; "decline" and "cancel" mean the user would not answer.
+type ElicitResult struct {
+	Action  string                 `json:"action"`
+	Content map[string]interface{} `json:"content,omitempty"`
+}
+
+// withElicitation attaches an Elicitor to ctx if the client can answer
+// elicitation requests and there is a stream to send them on. Without one the
+// request would wait out elicitationTimeout for an answer that cannot come.
+func (s *Session) withElicitation(ctx context.Context) context.Context {
+	if !s.supports(featureElicitation) || s.capabilities().Elicitation == nil || !s.canReach(ctx) {
+		return ctx
+	}
+	return context.WithValue(ctx, elicitationKey{}, &Elicitor{ctx: ctx, session: s})
+}
+
+// elicitorFrom returns the Elicitor for the current request, or nil.
+func elicitorFrom(ctx context.Context) *Elicitor {
+	e, _ := ctx.Value(elicitationKey{}).(*Elicitor)
+	return e
+}
+
+// Elicit shows the user message with a form for schema and waits for their
+// answer. Accepted content is checked against schema before it is returned.
+func (e *Elicitor) Elicit(message string, schema *JSONSchema) (ElicitResult, error) {
+	ctx, cancel := context.WithTimeout(e.ctx, elicitationTimeout)
+	defer cancel()
+
+	raw, err := e.session.request(ctx, "elicitation/create", ElicitParams{
+		Message:         message,
+		RequestedSchema: schema,
+	})
+	if err != nil {
+		return ElicitResult{}, err
+	}
+
+	var result ElicitResult
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return ElicitResult{}, fmt.Errorf("invalid elicitation result: %w", err)
+	}
+	switch result.Action {
+	case "accept":
+	case "decline", "cancel":
+		return result, nil
+	default:
+		return ElicitResult{}, fmt.Errorf("invalid elicitation action %q", result.Action)
+	}
+
+	if violations := schema.Validate(result.Content); len(violations) > 0 {
+		return ElicitResult{}, fmt.Errorf("elicitation answer does not match the form: %s", violations[0])
+	}
+	return result, nil
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// lockFile takes an exclusive lock shared with other copies of the server on
+// path+".lock", waiting for any holder to let go. Holding it across a read
+// and the write that depends on it keeps two processes from both acting on
+// the same stale state. The lock is advisory and only binds callers of
+// lockFile. Within a process, callers still need their own mutex.
+func lockFile(path string) (unlock func(), err error) {
+	f, err := os.OpenFile(path+".lock", os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("opening lock file: %w", err)
+	}
+	if err := lockExclusive(f); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("locking %s: %w", path, err)
+	}
+	return func() {
+		unlockExclusive(f)
+		f.Close()
+	}, nil
+}
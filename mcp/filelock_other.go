@@ -0,0 +1,11 @@
+//go:build !unix && !windows
+
+package main
+
+import "os"
+
+// Platforms without file locking fall back to the in-process mutexes alone.
+
+func lockExclusive(f *os.File) error { return nil }
+
+func unlockExclusive(f *os.File) error { return nil }
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// shutdownGrace is how long in-flight requests get to finish on shutdown
+// before they are cancelled.
+const shutdownGrace = 10 * time.Second
+
+// errSessionClosing is the context cause for messages that arrived after the
+// session stopped admitting work. Their requests are refused.
+var errSessionClosing = errors.New("session is closing")
+
+// sessionState follows the MCP lifecycle:
+//
+//	uninitialized -> initializing -> ready -> closing
+//
+// initialize moves a session to initializing, notifications/initialized to
+// ready, and EOF, a signal or the client hanging up to closing. Requests are
+// still accepted while initializing because concurrent dispatch means the
+// initialized notification can be overtaken by the client's first request.
+type sessionState int
+
+const (
+	stateUninitialized sessionState = iota
+	stateInitializing
+	stateReady
+	stateClosing
+)
+
+func (st sessionState) String() string {
+	switch st {
+	case stateUninitialized:
+		return "uninitialized"
+	case stateInitializing:
+		return "initializing"
+	case stateReady:
+		return "ready"
+	case stateClosing:
+		return "closing"
+	default:
+		return fmt.Sprintf("sessionState(%d)", int(st))
+	}
+}
+
+func (s *Session) currentState() sessionState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state
+}
+
+// transition moves the session from one state to another, reporting false if
+// it was not in the expected state.
+func (s *Session) transition(from, to sessionState) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.state != from {
+		return false
+	}
+	s.state = to
+	return true
+}
+
+// checkState rejects requests the session's state does not allow. ping is
+// always answered so keepalives work at any point; initialize guards its own
+// transition. Work admitted before the session started closing is allowed to
+// finish, so closing only refuses what arrived afterwards (see refused).
+func (s *Session) checkState(ctx context.Context, request JsonRpcRequest) *JsonRpcResponse {
+	if request.Method == "ping" {
+		return nil
+	}
+
+	if errors.Is(context.Cause(ctx), errSessionClosing) {
+		return newErrorResponse(request.ID, codeServerShuttingDown, "Server is shutting down")
+	}
+
+	if s.currentState() == stateUninitialized && request.Method != "initialize" {
+		return newErrorResponse(request.ID, codeServerNotInitialized, "Server not initialized")
+	}
+	return nil
+}
+
+// refused derives the context for a message the session would not admit.
+func refused(ctx context.Context) context.Context {
+	ctx, cancel := context.WithCancelCause(ctx)
+	cancel(errSessionClosing)
+	return ctx
+}
+
+// begin registers a unit of work with the session, refusing once the session
+// is closing so that nothing new starts while in-flight work drains.
+func (s *Session) begin() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.state == stateClosing {
+		return false
+	}
+	s.inFlight.Add(1)
+	return true
+}
+
+// shutdown stops the session from starting new work, gives in-flight requests
+// up to grace to finish, cancels whatever is left and closes the session.
+func (s *Session) shutdown(grace time.Duration) {
+	s.mu.Lock()
+	s.state = stateClosing
+	s.mu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		s.inFlight.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+	case <-time.After(grace):
+		fmt.Fprintf(os.Stderr, "Session %s: cancelling requests still running after %s\n", s.id, grace)
+		s.cancel()
+	}
+
+	s.close()
+}
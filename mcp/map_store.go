@@ -0,0 +1,324 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrMapNotFound is returned for a map ID the store has no record of.
+var ErrMapNotFound = errors.New("map not found")
+
+// Map statuses. A map is recorded as generating as soon as create_list
+// starts, so clients can subscribe to it before it is ready.
+const (
+	MapGenerating = "generating"
+	MapReady      = "ready"
+	MapFailed     = "failed"
+)
+
+// GeneratedMap is a physician map made by create_list, kept so it can be
+// read back as a resource later. Its ID is the embedded MapID.
+type GeneratedMap struct {
+	CreateListOutput
+	Status    string    `json:"status,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ready reports whether the map has finished. Maps saved before statuses
+// were recorded have none and are all finished.
+func (m GeneratedMap) ready() bool {
+	return m.Status == "" || m.Status == MapReady
+}
+
+// MapStore records generated maps in a JSON-lines file. Each change to a map
+// appends a line and the last line for an ID wins. A copy of each image is
+// kept next to the file. Images are downloaded when a map is first read if
+// create_list did not already fetch it, as the links the map API hands out
+// do not last forever.
+type MapStore struct {
+	path      string
+	imagesDir string
+
+	mu sync.Mutex
+}
+
+func OpenMapStore(path string) (*MapStore, error) {
+	imagesDir := filepath.Join(filepath.Dir(path), "maps")
+	if err := os.MkdirAll(imagesDir, 0o700); err != nil {
+		return nil, fmt.Errorf("creating map store directory: %w", err)
+	}
+	return &MapStore{path: path, imagesDir: imagesDir}, nil
+}
+
+// Reserve records a map that is about to be generated.
+func (s *MapStore) Reserve(points, radiusMiles int) (GeneratedMap, error) {
+	m := GeneratedMap{
+		CreateListOutput: CreateListOutput{
+			MapID:       newMapID(),
+			Points:      points,
+			RadiusMiles: radiusMiles,
+		},
+		Status:    MapGenerating,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return m, s.append(m)
+}
+
+// Complete records the finished map reserved as id, with its image if one
+// was already downloaded.
+func (s *MapStore) Complete(id string, output CreateListOutput, image []byte) (GeneratedMap, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, err := s.get(id)
+	if err != nil {
+		return GeneratedMap{}, err
+	}
+	output.MapID = id
+	m.CreateListOutput = output
+	m.Status = MapReady
+
+	if image != nil {
+		if err := os.WriteFile(s.imagePath(id), image, 0o600); err != nil {
+			return GeneratedMap{}, fmt.Errorf("saving map image: %w", err)
+		}
+	}
+	return m, s.append(m)
+}
+
+// Fail records that the map reserved as id could not be generated. Failed
+// maps are left out of List and cannot be read.
+func (s *MapStore) Fail(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, err := s.get(id)
+	if err != nil {
+		return err
+	}
+	m.Status = MapFailed
+	return s.append(m)
+}
+
+// append writes one line, first ending a line torn by a crash so that it
+// does not take this one with it. Callers hold s.mu.
+func (s *MapStore) append(m GeneratedMap) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	unlock, err := lockFile(s.path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("opening map store: %w", err)
+	}
+	defer f.Close()
+
+	torn, err := endsMidLine(f)
+	if err != nil {
+		return fmt.Errorf("reading map store: %w", err)
+	}
+	if torn {
+		data = append([]byte{'\n'}, data...)
+	}
+	if _, err := f.Write(data); err != nil {
+		return fmt.Errorf("saving map: %w", err)
+	}
+	return f.Sync()
+}
+
+// Get returns a map by ID, or ErrMapNotFound. Maps that failed are not
+// found.
+func (s *MapStore) Get(id string) (GeneratedMap, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, err := s.get(id)
+	if err == nil && m.Status == MapFailed {
+		return GeneratedMap{}, ErrMapNotFound
+	}
+	return m, err
+}
+
+// List returns every map that has not failed, newest first.
+func (s *MapStore) List() ([]GeneratedMap, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	all, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]GeneratedMap, 0, len(all))
+	for _, m := range all {
+		if m.Status != MapFailed {
+			list = append(list, m)
+		}
+	}
+	return list, nil
+}
+
+// get finds a map whatever its status. Callers hold s.mu.
+func (s *MapStore) get(id string) (GeneratedMap, error) {
+	all, err := s.load()
+	if err != nil {
+		return GeneratedMap{}, err
+	}
+	for _, m := range all {
+		if m.MapID == id {
+			return m, nil
+		}
+	}
+	return GeneratedMap{}, ErrMapNotFound
+}
+
+// load reads the latest state of every map, newest first. Callers hold s.mu.
+func (s *MapStore) load() ([]GeneratedMap, error) {
+	f, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("opening map store: %w", err)
+	}
+	defer f.Close()
+
+	var list []GeneratedMap
+	index := make(map[string]int)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var m GeneratedMap
+		if err := json.Unmarshal(scanner.Bytes(), &m); err != nil || m.MapID == "" {
+			// A torn last line from a crash is not worth failing over
+			continue
+		}
+		if i, ok := index[m.MapID]; ok {
+			list[i] = m
+			continue
+		}
+		index[m.MapID] = len(list)
+		list = append(list, m)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading map store: %w", err)
+	}
+
+	sort.SliceStable(list, func(i, j int) bool { return list[i].CreatedAt.After(list[j].CreatedAt) })
+	return list, nil
+}
+
+// Image returns the PNG for a map, downloading and keeping it if this is the
+// first time it is needed.
+func (s *MapStore) Image(ctx context.Context, m GeneratedMap) ([]byte, error) {
+	data, err := os.ReadFile(s.imagePath(m.MapID))
+	if err == nil {
+		return data, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	data, _, err = downloadMapImage(ctx, m.MapURL)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(s.imagePath(m.MapID), data, 0o600); err != nil {
+		fmt.Fprintf(os.Stderr, "Could not keep image for map %s: %s\n", m.MapID, err)
+	}
+	return data, nil
+}
+
+func (s *MapStore) imagePath(id string) string {
+	return filepath.Join(s.imagesDir, id+".png")
+}
+
+func newMapID() string {
+	b := make([]byte, 6)
+	rand.Read(b)
+	return "map_" + hex.EncodeToString(b)
+}
+
+// reserveMap records a map create_list is about to generate, so that the
+// caller can subscribe to it before it is ready. The resource list is only
+// announced as changed once the map is complete. It returns "" if the map
+// cannot be recorded; the map is still generated, just not kept.
+func reserveMap(points, radiusMiles int) string {
+	store, err := maps()
+	if err == nil {
+		var m GeneratedMap
+		if m, err = store.Reserve(points, radiusMiles); err == nil {
+			return m.MapID
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Could not save map: %s\n", err)
+	return ""
+}
+
+// completeMap stores a finished map, tells subscribers it is ready and
+// announces it to everyone else.
+func completeMap(id string, output CreateListOutput, image []byte) (GeneratedMap, error) {
+	store, err := maps()
+	if err != nil {
+		return GeneratedMap{}, err
+	}
+	m, err := store.Complete(id, output, image)
+	if err != nil {
+		return GeneratedMap{}, err
+	}
+	notifyResourceUpdated(mapResourceURI(id))
+	notifyResourcesChanged()
+	return m, nil
+}
+
+// failMap drops a map that could not be generated. Only subscribers are
+// told; the map was never announced to anyone else.
+func failMap(id string) {
+	store, err := maps()
+	if err == nil {
+		err = store.Fail(id)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not mark map %s as failed: %s\n", id, err)
+		return
+	}
+	notifyResourceUpdated(mapResourceURI(id))
+}
+
+var (
+	mapsOnce sync.Once
+	mapStore *MapStore
+	mapsErr  error
+)
+
+// maps returns the shared map store, opening it on first use.
+func maps() (*MapStore, error) {
+	mapsOnce.Do(func() {
+		dir, err := dataDir()
+		if err != nil {
+			mapsErr = err
+			return
+		}
+		mapStore, mapsErr = OpenMapStore(filepath.Join(dir, "maps.jsonl"))
+	})
+	return mapStore, mapsErr
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMapStoreKeepsMapsAfterTornLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "maps.jsonl")
+	store, err := OpenMapStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reserved, err := store.Reserve(500, 25)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"map_id":"map_torn","poi`)
+	f.Close()
+
+	if _, err := store.Complete(reserved.MapID, CreateListOutput{Points: 500, RadiusMiles: 25}, nil); err != nil {
+		t.Fatal(err)
+	}
+	m, err := store.Get(reserved.MapID)
+	if err != nil {
+		t.Fatalf("Get after a torn line: %v", err)
+	}
+	if !m.ready() {
+		t.Errorf("map status = %q, want it ready", m.Status)
+	}
+}
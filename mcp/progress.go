@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+)
+
+type progressKey struct{}
+
+// ProgressReporter emits notifications/progress for a request that supplied a
+// _meta.progressToken. A nil reporter is valid and reports nothing, so tool
+// handlers can report unconditionally.
+type ProgressReporter struct {
+	ctx     context.Context
+	session *Session
+	token   json.RawMessage
+
+	mu   sync.Mutex
+	last float64
+	sent bool
+}
+
+type ProgressParams struct {
+	ProgressToken json.RawMessage `json:"progressToken"`
+	Progress      float64         `json:"progress"`
+	Total         float64         `json:"total,omitempty"`
+	Message       string          `json:"message,omitempty"`
+}
+
+// withProgress attaches a reporter for token to ctx. Requests without a token
+// get no reporter.
+func (s *Session) withProgress(ctx context.Context, token json.RawMessage) context.Context {
+	if token == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, progressKey{}, &ProgressReporter{
+		ctx:     ctx,
+		session: s,
+		token:   token,
+	})
+}
+
+// progressFrom returns the reporter for the current request, or nil.
+func progressFrom(ctx context.Context) *ProgressReporter {
+	p, _ := ctx.Value(progressKey{}).(*ProgressReporter)
+	return p
+}
+
+// Report sends a progress update. MCP requires progress to increase with each
+// notification, so values that do not are dropped, as is anything reported
+// after the request was cancelled. A total of zero means the total is unknown.
+func (p *ProgressReporter) Report(progress, total float64, message string) {
+	if p == nil || p.ctx.Err() != nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.sent && progress <= p.last {
+		return
+	}
+	p.last = progress
+	p.sent = true
+
+	if !p.session.supports(featureProgressMessage) {
+		message = ""
+	}
+
+	p.session.sendRelated(p.ctx, JsonRpcNotification{
+		JsonRpc: "2.0",
+		Method:  "notifications/progress",
+		Params: ProgressParams{
+			ProgressToken: p.token,
+			Progress:      progress,
+			Total:         total,
+			Message:       message,
+		},
+	})
+}
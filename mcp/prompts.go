@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// promptPollInterval is how often the prompts directory is checked for
+// changes.
+const promptPollInterval = 2 * time.Second
+
+// defaultPrompts are the templates built into the server. Files in the
+// prompts directory add to them or replace them by name.
+//
+//go:embed prompts/*.json
+var defaultPrompts embed.FS
+
+type Prompt struct {
+	Name        string           `json:"name"`
+	Title       string           `json:"title,omitempty"`
+	Description string           `json:"description,omitempty"`
+	Arguments   []PromptArgument `json:"arguments,omitempty"`
+}
+
+type PromptArgument struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+}
+
+// PromptTemplate is a prompt as stored in a JSON file. Message texts are Go
+// text/template templates over the arguments, so {{.client}} is replaced by
+// the client argument; missing optional arguments are empty.
+type PromptTemplate struct {
+	Prompt
+	Messages []PromptTemplateMessage `json:"messages"`
+
+	texts []*template.Template
+}
+
+type PromptTemplateMessage struct {
+	Role string `json:"role"`
+	Text string `json:"text"`
+}
+
+type PromptMessage struct {
+	Role    string  `json:"role"`
+	Content Content `json:"content"`
+}
+
+type GetPromptParams struct {
+	Name      string            `json:"name"`
+	Arguments map[string]string `json:"arguments,omitempty"`
+}
+
+type GetPromptResult struct {
+	Description string          `json:"description,omitempty"`
+	Messages    []PromptMessage `json:"messages"`
+}
+
+// parsePromptTemplate decodes and checks one template file.
+func parsePromptTemplate(data []byte) (*PromptTemplate, error) {
+	var prompt PromptTemplate
+	if err := json.Unmarshal(data, &prompt); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	if prompt.Name == "" {
+		return nil, fmt.Errorf("missing name")
+	}
+	if len(prompt.Messages) == 0 {
+		return nil, fmt.Errorf("prompt %s has no messages", prompt.Name)
+	}
+
+	for i, message := range prompt.Messages {
+		if message.Role != "user" && message.Role != "assistant" {
+			return nil, fmt.Errorf("message #%d of %s has role %q, expected user or assistant", i+1, prompt.Name, message.Role)
+		}
+		text, err := template.New(prompt.Name).Option("missingkey=zero").Parse(message.Text)
+		if err != nil {
+			return nil, fmt.Errorf("message #%d of %s: %w", i+1, prompt.Name, err)
+		}
+		prompt.texts = append(prompt.texts, text)
+	}
+	return &prompt, nil
+}
+
+// render fills the template in. Every required argument must be given.
+func (p *PromptTemplate) render(arguments map[string]string) (GetPromptResult, error) {
+	for _, argument := range p.Arguments {
+		if argument.Required && strings.TrimSpace(arguments[argument.Name]) == "" {
+			return GetPromptResult{}, fmt.Errorf("missing required argument %q", argument.Name)
+		}
+	}
+
+	values := make(map[string]string, len(p.Arguments))
+	for _, argument := range p.Arguments {
+		values[argument.Name] = strings.TrimSpace(arguments[argument.Name])
+	}
+
+	result := GetPromptResult{Description: p.Description}
+	for i, text := range p.texts {
+		var buf bytes.Buffer
+		if err := text.Execute(&buf, values); err != nil {
+			return GetPromptResult{}, fmt.Errorf("rendering %s: %w", p.Name, err)
+		}
+		result.Messages = append(result.Messages, PromptMessage{
+			Role:    p.Messages[i].Role,
+			Content: Content{Type: "text", Text: buf.String()},
+		})
+	}
+	return result, nil
+}
+
+// PromptRegistry holds the prompt templates currently on offer. onChange is
+// called whenever a reload changes what prompts/list returns.
+type PromptRegistry struct {
+	mu       sync.RWMutex
+	prompts  []*PromptTemplate
+	onChange func()
+}
+
+var prompts = &PromptRegistry{onChange: notifyPromptsChanged}
+
+// List returns the prompts in name order.
+func (r *PromptRegistry) List() []Prompt {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	list := make([]Prompt, len(r.prompts))
+	for i, prompt := range r.prompts {
+		list[i] = prompt.Prompt
+	}
+	return list
+}
+
+// Get renders the named prompt.
+func (r *PromptRegistry) Get(name string, arguments map[string]string) (GetPromptResult, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, prompt := range r.prompts {
+		if prompt.Name == name {
+			return prompt.render(arguments)
+		}
+	}
+	return GetPromptResult{}, fmt.Errorf("unknown prompt: %s", name)
+}
+
+// replace swaps in a new set of templates.
+func (r *PromptRegistry) replace(templates []*PromptTemplate) {
+	before, _ := json.Marshal(r.List())
+
+	sort.Slice(templates, func(i, j int) bool { return templates[i].Name < templates[j].Name })
+	r.mu.Lock()
+	r.prompts = templates
+	r.mu.Unlock()
+
+	after, _ := json.Marshal(r.List())
+	if r.onChange != nil && !bytes.Equal(before, after) {
+		r.onChange()
+	}
+}
+
+// adaptPrompts drops the parts of prompt definitions the session's protocol
+// revision does not know about.
+func (s *Session) adaptPrompts(list []Prompt) []Prompt {
+	if s.supports(featureTitle) {
+		return list
+	}
+	adapted := make([]Prompt, len(list))
+	for i, prompt := range list {
+		prompt.Title = ""
+		adapted[i] = prompt
+	}
+	return adapted
+}
+
+// promptsDir is where extra templates are read from: RAVE_PROMPTS_DIR, or a
+// prompts folder in the data directory.
+func promptsDir() (string, error) {
+	if dir := os.Getenv("RAVE_PROMPTS_DIR"); dir != "" {
+		return dir, nil
+	}
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "prompts"), nil
+}
+
+// loadPrompts reads the built-in templates and then every *.json file in dir.
+// A file that fails to load is reported and skipped.
+func loadPrompts(dir string) []*PromptTemplate {
+	byName := make(map[string]*PromptTemplate)
+
+	load := func(fsys fs.FS, pattern, where string) {
+		paths, _ := fs.Glob(fsys, pattern)
+		for _, path := range paths {
+			data, err := fs.ReadFile(fsys, path)
+			if err == nil {
+				var prompt *PromptTemplate
+				if prompt, err = parsePromptTemplate(data); err == nil {
+					byName[prompt.Name] = prompt
+					continue
+				}
+			}
+			fmt.Fprintf(os.Stderr, "Skipping prompt template %s: %s\n", filepath.Join(where, path), err)
+		}
+	}
+
+	load(defaultPrompts, "prompts/*.json", "")
+	if dir != "" {
+		load(os.DirFS(dir), "*.json", dir)
+	}
+
+	templates := make([]*PromptTemplate, 0, len(byName))
+	for _, prompt := range byName {
+		templates = append(templates, prompt)
+	}
+	return templates
+}
+
+// watchPrompts loads the prompt templates and reloads them whenever the
+// prompts directory changes until ctx is done.
+func watchPrompts(ctx context.Context) {
+	dir, err := promptsDir()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Only built-in prompts are available: %s\n", err)
+		dir = ""
+	}
+
+	prompts.replace(loadPrompts(dir))
+	if dir == "" {
+		return
+	}
+
+	go watchFile(ctx, dir, promptPollInterval, func() {
+		fmt.Fprintf(os.Stderr, "Prompts in %s changed, reloading\n", dir)
+		prompts.replace(loadPrompts(dir))
+	})
+}
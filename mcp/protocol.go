@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// supportedProtocolVersions lists the MCP revisions this server speaks,
+// oldest first. Revisions are dates, so they order as plain strings.
+var supportedProtocolVersions = []string{"2024-11-05", "2025-03-26", "2025-06-18"}
+
+// defaultProtocolVersion is assumed for clients that do not say what they
+// want. It is the revision the server originally hard-coded.
+const defaultProtocolVersion = "2024-11-05"
+
+// protocolFeature names behaviour that only exists from a given revision on.
+type protocolFeature string
+
+const (
+	featureProgressMessage  protocolFeature = "progress message"
+	featureToolAnnotations  protocolFeature = "tool annotations"
+	featureTitle            protocolFeature = "display titles"
+	featureStructuredOutput protocolFeature = "structured tool output"
+	featureElicitation      protocolFeature = "elicitation"
+	featureResourceLinks    protocolFeature = "resource links"
+)
+
+// featureSince maps each feature to the first revision that has it.
+var featureSince = map[protocolFeature]string{
+	featureProgressMessage:  "2025-03-26",
+	featureToolAnnotations:  "2025-03-26",
+	featureTitle:            "2025-06-18",
+	featureStructuredOutput: "2025-06-18",
+	featureElicitation:      "2025-06-18",
+	featureResourceLinks:    "2025-06-18",
+}
+
+type InitializeParams struct {
+	ProtocolVersion string             `json:"protocolVersion"`
+	Capabilities    ClientCapabilities `json:"capabilities"`
+	ClientInfo      Implementation     `json:"clientInfo"`
+}
+
+type ClientCapabilities struct {
+	Roots       json.RawMessage `json:"roots,omitempty"`
+	Sampling    json.RawMessage `json:"sampling,omitempty"`
+	Elicitation json.RawMessage `json:"elicitation,omitempty"`
+}
+
+type Implementation struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// negotiateProtocolVersion picks the newest supported revision that is no
+// newer than the one requested, since a client that speaks a revision is
+// assumed to speak the ones before it. A request older than anything
+// supported gets the latest revision, and the client decides whether to
+// disconnect.
+func negotiateProtocolVersion(requested string) string {
+	if requested == "" {
+		return defaultProtocolVersion
+	}
+
+	for i := len(supportedProtocolVersions) - 1; i >= 0; i-- {
+		if supportedProtocolVersions[i] <= requested {
+			return supportedProtocolVersions[i]
+		}
+	}
+	return supportedProtocolVersions[len(supportedProtocolVersions)-1]
+}
+
+func isSupportedProtocolVersion(version string) bool {
+	for _, v := range supportedProtocolVersions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Session) handleInitialize(request JsonRpcRequest) *JsonRpcResponse {
+	var params InitializeParams
+	if len(request.Params) > 0 {
+		if err := json.Unmarshal(request.Params, &params); err != nil {
+			return newErrorResponse(request.ID, codeInvalidParams, "Invalid params")
+		}
+	}
+
+	if !s.transition(stateUninitialized, stateInitializing) {
+		return newErrorResponse(request.ID, codeInvalidRequest, "Invalid Request: session already initialized")
+	}
+
+	version := negotiateProtocolVersion(params.ProtocolVersion)
+
+	s.mu.Lock()
+	s.protocolVersion = version
+	s.clientCapabilities = params.Capabilities
+	s.clientInfo = params.ClientInfo
+	s.mu.Unlock()
+
+	fmt.Fprintf(os.Stderr, "Session %s: %s %s requested protocol %q, using %s\n",
+		s.id, params.ClientInfo.Name, params.ClientInfo.Version, params.ProtocolVersion, version)
+
+	return newResponse(request.ID, map[string]interface{}{
+		"protocolVersion": version,
+		"capabilities": map[string]interface{}{
+			"tools":     map[string]bool{"listChanged": true},
+			"prompts":   map[string]bool{"listChanged": true},
+			"resources": map[string]bool{"subscribe": true, "listChanged": true},
+		},
+		"serverInfo": map[string]interface{}{
+			"name":    "rave",
+			"version": "1.0.0",
+		},
+	})
+}
+
+// supports reports whether the session's negotiated revision has a feature.
+// Sessions that have not initialized support none of them.
+func (s *Session) supports(feature protocolFeature) bool {
+	s.mu.Lock()
+	version := s.protocolVersion
+	s.mu.Unlock()
+
+	return version != "" && version >= featureSince[feature]
+}
+
+func (s *Session) capabilities() ClientCapabilities {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.clientCapabilities
+}
+
+func (s *Session) client() Implementation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.clientInfo
+}
+
+// actor identifies the session's client for the campaign audit trail.
+func (s *Session) actor() Actor {
+	info := s.client()
+	return Actor{Client: info.Name, ClientVersion: info.Version, Session: s.id}
+}
@@ -0,0 +1,28 @@
+package main
+
+import "testing"
+
+func TestNegotiateProtocolVersion(t *testing.T) {
+	tests := []struct {
+		requested string
+		want      string
+	}{
+		{"", defaultProtocolVersion},
+		{"2024-11-05", "2024-11-05"},
+		{"2025-03-26", "2025-03-26"},
+		{"2025-06-18", "2025-06-18"},
+		// Between two supported revisions, the older one
+		{"2025-01-01", "2024-11-05"},
+		{"2025-05-01", "2025-03-26"},
+		// Newer than anything supported, the latest
+		{"2099-01-01", "2025-06-18"},
+		// Older than anything supported, still the latest
+		{"2024-01-01", "2025-06-18"},
+	}
+
+	for _, tt := range tests {
+		if got := negotiateProtocolVersion(tt.requested); got != tt.want {
+			t.Errorf("negotiateProtocolVersion(%q) = %q, want %q", tt.requested, got, tt.want)
+		}
+	}
+}
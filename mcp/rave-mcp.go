@@ -2,60 +2,132 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path/filepath"
 	"runtime"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 )
 
+// JSON-RPC 2.0 error codes
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+	codeInternalError  = -32603
+	
+	// Implementation-defined server errors
+	codeServerShuttingDown   = -32000
+	codeServerNotInitialized = -32002
+	
+	// resources/read of a URI that does not exist. MCP suggests -32002, but
+	// that already means the session is not initialized here
+	codeResourceNotFound = -32003
+)
+
+// JsonRpcRequest keeps the ID as raw JSON so string, number and null IDs are
+// echoed back exactly as the client sent them. A nil ID means the message is a
+// notification.
 type JsonRpcRequest struct {
-	ID      int             `json:"id"`
+	JsonRpc string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
 	Method  string          `json:"method"`
 	Params  json.RawMessage `json:"params,omitempty"`
 }
 
+// IsNotification reports whether the request must not be answered. An
+// explicit "id": null is still a request and gets a reply with a null ID.
+func (r JsonRpcRequest) IsNotification() bool {
+	return r.ID == nil
+}
+
 type JsonRpcResponse struct {
-	JsonRpc string      `json:"jsonrpc"`
-	ID      int         `json:"id"`
-	Result  interface{} `json:"result,omitempty"`
-	Error   *JsonRpcError `json:"error,omitempty"`
+	JsonRpc string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *JsonRpcError   `json:"error,omitempty"`
 }
 
 type JsonRpcError struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+// JsonRpcNotification is a server-initiated message that expects no reply.
+type JsonRpcNotification struct {
+	JsonRpc string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
 }
 
 type Tool struct {
-	Name        string      `json:"name"`
-	Description string      `json:"description"`
-	InputSchema interface{} `json:"inputSchema"`
+	Name         string           `json:"name"`
+	Title        string           `json:"title,omitempty"`
+	Description  string           `json:"description"`
+	InputSchema  *JSONSchema      `json:"inputSchema"`
+	OutputSchema *JSONSchema      `json:"outputSchema,omitempty"`
+	Annotations  *ToolAnnotations `json:"annotations,omitempty"`
 }
 
 type ToolCallParams struct {
-	Name      string                 `json:"name"`
-	Arguments map[string]interface{} `json:"arguments"`
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+	Meta      *RequestMeta    `json:"_meta,omitempty"`
 }
 
-type TextContent struct {
-	Type string `json:"type"`
-	Text string `json:"text"`
+type RequestMeta struct {
+	ProgressToken json.RawMessage `json:"progressToken,omitempty"`
 }
 
 type ToolResult struct {
-	Content []TextContent `json:"content"`
-	IsError bool          `json:"isError,omitempty"`
+	Content           []Content `json:"content"`
+	StructuredContent interface{}   `json:"structuredContent,omitempty"`
+	IsError           bool          `json:"isError,omitempty"`
 }
 
 func main() {
+	httpAddr := flag.String("http", "", "serve MCP over Streamable HTTP on this address (e.g. :8080) instead of stdio")
+	configPath := flag.String("config", "", "config file with the API key and extra tools (default $RAVE_CONFIG or the user config dir)")
+	flag.Parse()
+	
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	
+	if *configPath == "" {
+		*configPath = os.Getenv("RAVE_CONFIG")
+	}
+	if *configPath == "" {
+		*configPath = defaultConfigPath()
+	}
+	
+	if *httpAddr != "" {
+		watchConfig(ctx, *configPath)
+		watchPrompts(ctx)
+		watchCampaigns(ctx)
+		if err := serveHTTP(ctx, *httpAddr); err != nil {
+			fmt.Fprintf(os.Stderr, "HTTP server error: %s\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	
 	// Check if we're being called by MCP (stdin has data) or double-clicked (interactive)
 	stat, _ := os.Stdin.Stat()
 	if (stat.Mode() & os.ModeCharDevice) != 0 {
@@ -65,204 +137,398 @@ func main() {
 	}
 	
 	// MCP mode - handle JSON-RPC over stdin
-	scanner := bufio.NewScanner(os.Stdin)
+	watchConfig(ctx, *configPath)
+	watchPrompts(ctx)
+	watchCampaigns(ctx)
+	serveStdio(ctx)
+}
+
+// handleMessage dispatches a single request or a batch. It returns a
+// *JsonRpcResponse, a []*JsonRpcResponse for batches, or nil when there is
+// nothing to send back (notifications only).
+func (s *Session) handleMessage(ctx context.Context, data []byte) interface{} {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '[' {
+		if responses := s.handleBatch(ctx, data); responses != nil {
+			return responses
+		}
+		return nil
+	}
 	
-	for scanner.Scan() {
-		line := scanner.Text()
-		var request JsonRpcRequest
-		
-		if err := json.Unmarshal([]byte(line), &request); err != nil {
-			sendError(0, -32700, "Parse error")
-			continue
+	if response := s.handleSingle(ctx, data); response != nil {
+		return response
+	}
+	return nil
+}
+
+// handleBatch dispatches the elements of a batch through handleRequest in
+// parallel, at most maxConcurrentRequests at a time, and collects the replies
+// in batch order. Notifications contribute nothing, so a batch made only of
+// notifications returns nil.
+func (s *Session) handleBatch(ctx context.Context, data []byte) interface{} {
+	var elements []json.RawMessage
+	if err := json.Unmarshal(data, &elements); err != nil {
+		return newErrorResponse(nil, codeParseError, "Parse error")
+	}
+	
+	if len(elements) == 0 {
+		return newErrorResponse(nil, codeInvalidRequest, "Invalid Request: empty batch")
+	}
+	
+	results := make([]*JsonRpcResponse, len(elements))
+	slots := make(chan struct{}, maxConcurrentRequests)
+	var wg sync.WaitGroup
+	for i, element := range elements {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			results[i] = s.handleSingle(ctx, element)
+		}()
+	}
+	wg.Wait()
+	
+	responses := make([]*JsonRpcResponse, 0, len(results))
+	for _, response := range results {
+		if response != nil {
+			responses = append(responses, response)
 		}
-		
-		handleRequest(request)
 	}
+	
+	if len(responses) == 0 {
+		return nil
+	}
+	return responses
+}
+
+// handleSingle parses and dispatches one JSON-RPC message, returning the
+// response to send or nil when the message was a notification, a reply to
+// one of the server's requests, or the client cancelled the request. A
+// panicking handler is reported as an internal error instead of taking down
+// the server.
+func (s *Session) handleSingle(ctx context.Context, data []byte) (response *JsonRpcResponse) {
+	// Batches may carry replies to the server's own requests
+	if s.deliverResponse(data) {
+		return nil
+	}
+	
+	request, errResponse := parseRequest(data)
+	if errResponse != nil {
+		return errResponse
+	}
+	
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Fprintf(os.Stderr, "Panic handling %s: %v\n", request.Method, r)
+			if !request.IsNotification() {
+				response = newErrorResponse(request.ID, codeInternalError, "Internal error")
+			}
+		}
+	}()
+	
+	if request.IsNotification() {
+		s.handleNotification(request)
+		return nil
+	}
+	
+	ctx, done := s.track(ctx, request.ID)
+	defer done()
+	
+	// Cancelled before a worker got to it, so it must not run at all
+	if errors.Is(context.Cause(ctx), errRequestCancelled) {
+		return nil
+	}
+	
+	response = s.handleRequest(ctx, request)
+	
+	// The client has already given up on a cancelled request
+	if errors.Is(context.Cause(ctx), errRequestCancelled) {
+		return nil
+	}
+	return response
 }
 
-func handleRequest(request JsonRpcRequest) {
+// parseRequest decodes a JSON-RPC request. If the message is not valid JSON or
+// not a valid request object, the error response to send back is returned.
+func parseRequest(data []byte) (JsonRpcRequest, *JsonRpcResponse) {
+	var request JsonRpcRequest
+	
+	if !json.Valid(data) {
+		return request, newErrorResponse(nil, codeParseError, "Parse error")
+	}
+	
+	// Valid JSON of the wrong shape (a bare number, "method": 1, ...) is an
+	// invalid request rather than a parse error
+	if err := json.Unmarshal(data, &request); err != nil {
+		return request, newErrorResponse(peekRequestID(data), codeInvalidRequest, "Invalid Request")
+	}
+	
+	if !isValidID(request.ID) {
+		return request, newErrorResponse(nil, codeInvalidRequest, "Invalid Request: id must be a string, number or null")
+	}
+	
+	if request.JsonRpc != "2.0" {
+		return request, newErrorResponse(request.ID, codeInvalidRequest, `Invalid Request: "jsonrpc" must be "2.0"`)
+	}
+	
+	if request.Method == "" {
+		return request, newErrorResponse(request.ID, codeInvalidRequest, "Invalid Request: missing method")
+	}
+	
+	return request, nil
+}
+
+// peekRequestID extracts the ID from a malformed request so the error can
+// still be correlated by the client. It returns nil if no usable ID exists.
+func peekRequestID(data []byte) json.RawMessage {
+	var envelope struct {
+		ID json.RawMessage `json:"id"`
+	}
+	if err := json.Unmarshal(data, &envelope); err != nil || !isValidID(envelope.ID) {
+		return nil
+	}
+	return envelope.ID
+}
+
+// isValidID reports whether a raw ID is absent, a string, a number or null.
+func isValidID(id json.RawMessage) bool {
+	if id == nil {
+		return true
+	}
+	switch c := id[0]; {
+	case c == '"', c == '-', c >= '0' && c <= '9':
+		return true
+	default:
+		return string(id) == "null"
+	}
+}
+
+func (s *Session) handleRequest(ctx context.Context, request JsonRpcRequest) *JsonRpcResponse {
+	if errResponse := s.checkState(ctx, request); errResponse != nil {
+		return errResponse
+	}
+	
 	switch request.Method {
 	case "initialize":
-		sendResponse(request.ID, map[string]interface{}{
-			"protocolVersion": "2024-11-05",
-			"capabilities": map[string]interface{}{
-				"tools": map[string]bool{"listChanged": true},
-			},
-			"serverInfo": map[string]interface{}{
-				"name":    "rave",
-				"version": "1.0.0",
-			},
-		})
+		return s.handleInitialize(request)
 		
-	case "notifications/initialized":
-		// No response needed for notifications
-		return
+	case "ping":
+		return newResponse(request.ID, map[string]interface{}{})
 		
 	case "tools/list":
-		sendResponse(request.ID, handleListTools())
+		return newResponse(request.ID, map[string]interface{}{"tools": s.adaptTools(tools.List())})
 		
 	case "tools/call":
 		var params ToolCallParams
 		if err := json.Unmarshal(request.Params, &params); err != nil {
-			sendError(request.ID, -32602, "Invalid params")
-			return
+			return newErrorResponse(request.ID, codeInvalidParams, "Invalid params")
+		}
+		if params.Meta != nil {
+			ctx = s.withProgress(ctx, params.Meta.ProgressToken)
 		}
-		sendResponse(request.ID, handleCallTool(params.Name, params.Arguments))
+		ctx = withActor(ctx, s.actor())
+		ctx = s.withElicitation(ctx)
+		result, err := tools.Call(ctx, params.Name, params.Arguments)
+		var argsErr *ArgumentsError
+		if errors.As(err, &argsErr) {
+			response := newErrorResponse(request.ID, codeInvalidParams, argsErr.Error())
+			response.Error.Data = argsErr
+			return response
+		}
+		return newResponse(request.ID, s.adaptResult(result))
 		
 	case "prompts/list":
-		sendResponse(request.ID, map[string]interface{}{"prompts": []interface{}{}})
+		return newResponse(request.ID, map[string]interface{}{"prompts": s.adaptPrompts(prompts.List())})
+		
+	case "prompts/get":
+		var params GetPromptParams
+		if err := json.Unmarshal(request.Params, &params); err != nil {
+			return newErrorResponse(request.ID, codeInvalidParams, "Invalid params")
+		}
+		result, err := prompts.Get(params.Name, params.Arguments)
+		if err != nil {
+			return newErrorResponse(request.ID, codeInvalidParams, err.Error())
+		}
+		return newResponse(request.ID, result)
 		
 	case "resources/list":
-		sendResponse(request.ID, map[string]interface{}{"resources": []interface{}{}})
+		var params ListResourcesParams
+		if len(request.Params) > 0 {
+			if err := json.Unmarshal(request.Params, &params); err != nil {
+				return newErrorResponse(request.ID, codeInvalidParams, "Invalid params")
+			}
+		}
+		result, err := listResources(ctx, params.Cursor)
+		if errors.Is(err, errInvalidCursor) {
+			return newErrorResponse(request.ID, codeInvalidParams, "Invalid params: unknown cursor")
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error listing resources: %s\n", err)
+			return newErrorResponse(request.ID, codeInternalError, "Internal error: "+err.Error())
+		}
+		result.Resources = s.adaptResources(result.Resources)
+		return newResponse(request.ID, result)
+		
+	case "resources/templates/list":
+		return newResponse(request.ID, map[string]interface{}{"resourceTemplates": s.adaptResourceTemplates(resourceTemplates)})
+		
+	case "resources/read":
+		var params ReadResourceParams
+		if err := json.Unmarshal(request.Params, &params); err != nil || params.URI == "" {
+			return newErrorResponse(request.ID, codeInvalidParams, "Invalid params: uri is required")
+		}
+		result, err := readResource(ctx, params.URI)
+		var notFound *ResourceNotFoundError
+		if errors.As(err, &notFound) {
+			response := newErrorResponse(request.ID, codeResourceNotFound, notFound.Error())
+			response.Error.Data = map[string]string{"uri": notFound.URI}
+			return response
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading %s: %s\n", params.URI, err)
+			return newErrorResponse(request.ID, codeInternalError, "Internal error: "+err.Error())
+		}
+		return newResponse(request.ID, result)
+		
+	case "resources/subscribe", "resources/unsubscribe":
+		var params ReadResourceParams
+		if err := json.Unmarshal(request.Params, &params); err != nil || params.URI == "" {
+			return newErrorResponse(request.ID, codeInvalidParams, "Invalid params: uri is required")
+		}
+		if !subscribableURI(params.URI) {
+			response := newErrorResponse(request.ID, codeResourceNotFound, "Resource not found: "+params.URI)
+			response.Error.Data = map[string]string{"uri": params.URI}
+			return response
+		}
+		if request.Method == "resources/subscribe" {
+			s.subscribe(params.URI)
+		} else {
+			s.unsubscribe(params.URI)
+		}
+		return newResponse(request.ID, map[string]interface{}{})
 		
 	default:
-		sendError(request.ID, -32601, "Method not found")
-	}
-}
-
-func handleListTools() map[string]interface{} {
-	tools := []Tool{
-		{
-			Name:        "rave",
-			Description: "Says hello from Rave MCP server",
-			InputSchema: map[string]interface{}{
-				"type": "object",
-				"properties": map[string]interface{}{
-					"name": map[string]interface{}{
-						"type":        "string",
-						"description": "Name to greet (optional, defaults to 'World')",
-					},
-				},
-			},
-		},
-		{
-			Name:        "start_campaign_creation",
-			Description: "Start the interactive campaign creation process - will ask user for required information step by step",
-			InputSchema: map[string]interface{}{
-				"type":                 "object",
-				"properties":           map[string]interface{}{},
-				"additionalProperties": false,
-			},
+		return newErrorResponse(request.ID, codeMethodNotFound, "Method not found")
+	}
+}
+
+type RaveInput struct {
+	Name string `json:"name,omitempty" description:"Name to greet (optional, defaults to 'World')"`
+}
+
+type StartCampaignCreationInput struct{}
+
+type CreateCampaignInput struct {
+	CampaignName string   `json:"campaign_name,omitempty" description:"Name of the campaign (required; if left out, the user may be asked for it)"`
+	Description  string   `json:"description,omitempty" description:"Campaign description (required; if left out, the user may be asked for it)"`
+	ClientName   string   `json:"client_name,omitempty" description:"Client name (required; if left out, the user may be asked for it)"`
+	Budget       *float64 `json:"budget,omitempty" description:"Campaign budget (optional)" minimum:"0"`
+	Channels     []string `json:"channels,omitempty" description:"Marketing channels (e.g., email, social, ads)"`
+}
+
+type CreateListInput struct {
+	Count        int      `json:"count" description:"Number of physicians to display (e.g., 1000)" minimum:"10" maximum:"10000"`
+	Radius       int      `json:"radius,omitempty" description:"Radius in miles (optional, defaults to 50)" minimum:"5" maximum:"200"`
+	Lat          *float64 `json:"lat,omitempty" description:"Center latitude (optional, defaults to San Antonio)"`
+	Lon          *float64 `json:"lon,omitempty" description:"Center longitude (optional, defaults to San Antonio)"`
+	Clusters     int      `json:"clusters,omitempty" description:"Maximum number of clusters (optional, defaults to 50)" minimum:"1" maximum:"100"`
+	IncludeImage bool     `json:"include_image,omitempty" description:"Also return the map image itself so it can be viewed and described (optional)"`
+}
+
+type CreateListOutput struct {
+	MapID             string  `json:"map_id,omitempty" description:"ID of the saved map, readable as the resource rave://maps/{map_id}"`
+	MapURL            string  `json:"map_url" description:"Link to the generated map"`
+	Points            int     `json:"points" description:"Number of physicians on the map"`
+	RadiusMiles       int     `json:"radius_miles" description:"Radius of the mapped area in miles"`
+	ClustersGenerated int     `json:"clusters_generated" description:"Number of clusters drawn on the map"`
+	CenterLat         float64 `json:"center_lat" description:"Latitude of the map center"`
+	CenterLon         float64 `json:"center_lon" description:"Longitude of the map center"`
+}
+
+// tools is the registry every session lists and calls tools from. Which
+// tools are enabled follows the config file, see applyConfig.
+var tools = builtinTools()
+
+func builtinTools() *ToolRegistry {
+	r := NewToolRegistry()
+	r.onChange = notifyToolsChanged
+	
+	RegisterTool(r, Tool{
+		Name:        "rave",
+		Title:       "Say Hello",
+		Description: "Says hello from Rave MCP server",
+		Annotations: &ToolAnnotations{
+			ReadOnlyHint:   true,
+			IdempotentHint: true,
 		},
-		{
-			Name:        "create_campaign",
-			Description: "Create a new marketing campaign with all required information provided",
-			InputSchema: map[string]interface{}{
-				"type": "object",
-				"properties": map[string]interface{}{
-					"campaign_name": map[string]interface{}{
-						"type":        "string",
-						"description": "Name of the campaign (required)",
-					},
-					"description": map[string]interface{}{
-						"type":        "string",
-						"description": "Campaign description (required)",
-					},
-					"client_name": map[string]interface{}{
-						"type":        "string",
-						"description": "Client name (required)",
-					},
-					"budget": map[string]interface{}{
-						"type":        "number",
-						"description": "Campaign budget (optional)",
-					},
-					"channels": map[string]interface{}{
-						"type":        "array",
-						"items":       map[string]string{"type": "string"},
-						"description": "Marketing channels (e.g., email, social, ads)",
-					},
-				},
-				"required": []string{"campaign_name", "description", "client_name"},
-			},
+	}, handleRave)
+	
+	// Starting only creates a draft, see draft_tools.go for the rest
+	RegisterTool(r, Tool{
+		Name:         "start_campaign_creation",
+		Title:        "Start Campaign Creation",
+		Description:  "Start the interactive campaign creation process - will ask user for required information step by step",
+		OutputSchema: schemaOf[CampaignDraftOutput](),
+		Annotations:  &ToolAnnotations{},
+	}, handleStartCampaignCreation)
+	
+	// Creating a campaign will launch paid ads once it is wired to Google Ads
+	RegisterTool(r, Tool{
+		Name:         "create_campaign",
+		Title:        "Create Campaign",
+		Description:  "Create a new marketing campaign with all required information provided. Missing details are asked for when the client supports it",
+		OutputSchema: schemaOf[Campaign](),
+		Annotations: &ToolAnnotations{
+			DestructiveHint: true,
+			OpenWorldHint:   true,
 		},
-		{
-			Name:        "create_list",
-			Description: "Create a physician distribution map showing the specified number of physicians in a geographic area",
-			InputSchema: map[string]interface{}{
-				"type": "object",
-				"properties": map[string]interface{}{
-					"count": map[string]interface{}{
-						"type":        "integer",
-						"description": "Number of physicians to display (e.g., 1000)",
-						"minimum":     10,
-						"maximum":     10000,
-					},
-					"radius": map[string]interface{}{
-						"type":        "integer",
-						"description": "Radius in miles (optional, defaults to 50)",
-						"minimum":     5,
-						"maximum":     200,
-					},
-					"lat": map[string]interface{}{
-						"type":        "number",
-						"description": "Center latitude (optional, defaults to San Antonio)",
-					},
-					"lon": map[string]interface{}{
-						"type":        "number",
-						"description": "Center longitude (optional, defaults to San Antonio)",
-					},
-					"clusters": map[string]interface{}{
-						"type":        "integer",
-						"description": "Maximum number of clusters (optional, defaults to 50)",
-						"minimum":     1,
-						"maximum":     100,
-					},
-				},
-				"required": []string{"count"},
-			},
+	}, handleCreateCampaign)
+	
+	// Every call renders a new map, but nothing existing is changed
+	RegisterTool(r, Tool{
+		Name:         "create_list",
+		Title:        "Create Physician Map",
+		Description:  "Create a physician distribution map showing the specified number of physicians in a geographic area",
+		OutputSchema: schemaOf[CreateListOutput](),
+		Annotations: &ToolAnnotations{
+			OpenWorldHint: true,
 		},
-	}
+	}, handleCreateList)
 	
-	return map[string]interface{}{"tools": tools}
+	registerCampaignTools(r)
+	registerDraftTools(r)
+	
+	return r
 }
 
-func handleCallTool(name string, arguments map[string]interface{}) ToolResult {
-	switch name {
-	case "rave":
-		greetingName := "World"
-		if name, ok := arguments["name"].(string); ok {
-			greetingName = name
-		}
-		return ToolResult{
-			Content: []TextContent{{
-				Type: "text",
-				Text: fmt.Sprintf("Hello %s! This is Rave MCP Server 🎉", greetingName),
-			}},
-		}
-		
-	case "start_campaign_creation":
-		return ToolResult{
-			Content: []TextContent{{
-				Type: "text",
-				Text: "🚀 Let's create a new campaign! I need some information from you:\n\n1. **Campaign Name**: What would you like to call this campaign?\n2. **Client Name**: Which client is this campaign for?\n3. **Description**: Can you describe what this campaign is about?\n\nOptionally, you can also provide:\n- **Budget**: What's the budget for this campaign?\n- **Channels**: Which marketing channels do you want to use? (e.g., email, social, google-ads, facebook-ads)\n\nOnce you provide these details, I'll create the campaign for you!",
-			}},
-		}
-		
-	case "create_campaign":
-		return handleCreateCampaign(arguments)
-		
-	case "create_list":
-		return handleCreateList(arguments)
-		
-	default:
-		return ToolResult{
-			Content: []TextContent{{
-				Type: "text",
-				Text: fmt.Sprintf("Unknown tool: %s", name),
-			}},
-			IsError: true,
-		}
+func handleRave(ctx context.Context, input RaveInput) ToolResult {
+	greetingName := "World"
+	if input.Name != "" {
+		greetingName = input.Name
+	}
+	return ToolResult{
+		Content: []Content{{
+			Type: "text",
+			Text: fmt.Sprintf("Hello %s! This is Rave MCP Server 🎉", greetingName),
+		}},
 	}
 }
 
-func handleCreateCampaign(arguments map[string]interface{}) ToolResult {
-	campaignName := getString(arguments, "campaign_name")
-	description := getString(arguments, "description")
-	clientName := getString(arguments, "client_name")
+func handleCreateCampaign(ctx context.Context, input CreateCampaignInput) ToolResult {
+	if elicitor := elicitorFrom(ctx); elicitor != nil {
+		if result, stop := askForMissingFields(elicitor, &input); stop {
+			return result
+		}
+	}
+	
+	campaignName := input.CampaignName
+	description := input.Description
+	clientName := input.ClientName
 	
 	if campaignName == "" {
 		return ToolResult{
-			Content: []TextContent{{
+			Content: []Content{{
 				Type: "text",
 				Text: "❌ Campaign name is required. Please ask the user: What would you like to name this campaign?",
 			}},
@@ -272,7 +538,7 @@ func handleCreateCampaign(arguments map[string]interface{}) ToolResult {
 	
 	if description == "" {
 		return ToolResult{
-			Content: []TextContent{{
+			Content: []Content{{
 				Type: "text",
 				Text: "❌ Campaign description is required. Please ask the user: Can you describe what this campaign is about?",
 			}},
@@ -282,7 +548,7 @@ func handleCreateCampaign(arguments map[string]interface{}) ToolResult {
 	
 	if clientName == "" {
 		return ToolResult{
-			Content: []TextContent{{
+			Content: []Content{{
 				Type: "text",
 				Text: "❌ Client name is required. Please ask the user: Which client is this campaign for?",
 			}},
@@ -290,38 +556,132 @@ func handleCreateCampaign(arguments map[string]interface{}) ToolResult {
 		}
 	}
 	
-	responseText := fmt.Sprintf("Campaign Created Successfully! 🎉\n\nCampaign Details:\n• Name: %s\n• Client: %s\n• Description: %s", campaignName, clientName, description)
+	store, err := campaigns()
+	if err != nil {
+		return campaignStoreError(err)
+	}
 	
-	if budget, ok := arguments["budget"].(float64); ok {
-		responseText += fmt.Sprintf("\n• Budget: $%.2f", budget)
+	campaign, err := store.Create(ctx, Campaign{
+		CampaignName: campaignName,
+		ClientName:   clientName,
+		Description:  description,
+		Budget:       input.Budget,
+		Channels:     input.Channels,
+	})
+	if err != nil {
+		return campaignStoreError(err)
 	}
 	
-	if channels, ok := arguments["channels"].([]interface{}); ok && len(channels) > 0 {
-		channelStrs := make([]string, len(channels))
-		for i, ch := range channels {
-			if str, ok := ch.(string); ok {
-				channelStrs[i] = str
-			}
+	return campaignCreatedResult(campaign)
+}
+
+// askForMissingFields shows the user a form for the required campaign fields
+// the model left out and fills input in from the answer. It returns stop when
+// the call should end with result instead. If the form could not be shown the
+// call carries on, and the usual "Please ask the user" errors follow.
+func askForMissingFields(elicitor *Elicitor, input *CreateCampaignInput) (result ToolResult, stop bool) {
+	fields := Campaign{
+		CampaignName: input.CampaignName,
+		ClientName:   input.ClientName,
+		Description:  input.Description,
+	}
+	
+	schema := &JSONSchema{Type: "object", Properties: map[string]*JSONSchema{}}
+	for _, field := range draftFields {
+		if field.required && !field.isSet(fields) {
+			schema.Properties[field.name] = &JSONSchema{Type: "string", Description: field.question}
+			schema.Required = append(schema.Required, field.name)
 		}
-		responseText += fmt.Sprintf("\n• Channels: %v", channelStrs)
+	}
+	if len(schema.Required) == 0 {
+		return ToolResult{}, false
 	}
 	
-	responseText += "\n\n✅ Campaign is ready for launch!"
+	answer, err := elicitor.Elicit("A few more details are needed to create this campaign.", schema)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not ask for campaign details: %s\n", err)
+		return ToolResult{}, false
+	}
+	
+	switch answer.Action {
+	case "decline":
+		return ToolResult{
+			Content: []Content{{
+				Type: "text",
+				Text: "The user declined to give the missing campaign details, so no campaign was created.",
+			}},
+		}, true
+	case "cancel":
+		return ToolResult{
+			Content: []Content{{
+				Type: "text",
+				Text: "The user dismissed the campaign details form, so no campaign was created.",
+			}},
+		}, true
+	}
+	
+	for _, name := range schema.Required {
+		field, _ := findDraftField(name)
+		if err := field.set(&fields, answer.Content[name]); err != nil {
+			return ToolResult{
+				Content: []Content{{
+					Type: "text",
+					Text: fmt.Sprintf("❌ That %s was not accepted: %s. Please ask the user: %s", name, err.Error(), field.question),
+				}},
+				IsError: true,
+			}, true
+		}
+	}
+	
+	input.CampaignName = fields.CampaignName
+	input.ClientName = fields.ClientName
+	input.Description = fields.Description
+	return ToolResult{}, false
+}
+
+// campaignStoreError reports a campaign store failure as a tool error.
+func campaignStoreError(err error) ToolResult {
+	fmt.Fprintf(os.Stderr, "Campaign store error: %s\n", err)
+	return ToolResult{
+		Content: []Content{{
+			Type: "text",
+			Text: fmt.Sprintf("❌ Could not access saved campaigns: %s", err.Error()),
+		}},
+		IsError: true,
+	}
+}
+
+func campaignCreatedResult(campaign Campaign) ToolResult {
+	responseText := fmt.Sprintf("Campaign Created Successfully! 🎉\n\nCampaign Details:\n• ID: %s\n• Name: %s\n• Client: %s\n• Description: %s", campaign.ID, campaign.CampaignName, campaign.ClientName, campaign.Description)
+	
+	if campaign.Budget != nil {
+		responseText += fmt.Sprintf("\n• Budget: $%.2f", *campaign.Budget)
+	}
+	
+	if len(campaign.Channels) > 0 {
+		responseText += fmt.Sprintf("\n• Channels: %v", campaign.Channels)
+	}
+	
+	responseText += fmt.Sprintf("\n\n✅ Campaign saved as a %s.", campaign.Status)
 	
 	return ToolResult{
-		Content: []TextContent{{
+		Content: []Content{{
 			Type: "text",
 			Text: responseText,
 		}},
+		StructuredContent: campaign,
 	}
 }
 
-func handleCreateList(arguments map[string]interface{}) ToolResult {
+func handleCreateList(ctx context.Context, input CreateListInput) (result ToolResult) {
+	progress := progressFrom(ctx)
+	progress.Report(0, 4, "Validating request")
+	
 	// Check API key first
-	apiKey := os.Getenv("RAVE_API_KEY")
-	if apiKey != "1234" {
+	apiKey := currentAPIKey()
+	if !validAPIKey(apiKey) {
 		return ToolResult{
-			Content: []TextContent{{
+			Content: []Content{{
 				Type: "text",
 				Text: "❌ Invalid API key. Please contact your administrator to get a valid API key for Rave services.",
 			}},
@@ -329,10 +689,10 @@ func handleCreateList(arguments map[string]interface{}) ToolResult {
 		}
 	}
 	
-	count := getInt(arguments, "count")
+	count := input.Count
 	if count == 0 {
 		return ToolResult{
-			Content: []TextContent{{
+			Content: []Content{{
 				Type: "text",
 				Text: "❌ Please specify the number of physicians to display. Example: 'create list with 1000 candidates'",
 			}},
@@ -340,22 +700,36 @@ func handleCreateList(arguments map[string]interface{}) ToolResult {
 		}
 	}
 	
+	// Clients can subscribe to the map while it is generated. Unless it is
+	// stored as complete, it is marked failed however the handler ends,
+	// panics included.
+	mapID := reserveMap(count, intWithDefault(input.Radius, 50))
+	mapSaved := false
+	if mapID != "" {
+		progress.Report(0.5, 4, fmt.Sprintf("Generating %s", mapResourceURI(mapID)))
+		defer func() {
+			if !mapSaved {
+				failMap(mapID)
+			}
+		}()
+	}
+	
 	// Prepare API parameters
 	params := url.Values{}
 	params.Set("points", strconv.Itoa(count))
-	params.Set("radius", strconv.Itoa(getIntWithDefault(arguments, "radius", 50)))
-	params.Set("clusters", strconv.Itoa(getIntWithDefault(arguments, "clusters", 50)))
+	params.Set("radius", strconv.Itoa(intWithDefault(input.Radius, 50)))
+	params.Set("clusters", strconv.Itoa(intWithDefault(input.Clusters, 50)))
 	
 	// Add API key if provided
-	if apiKey := os.Getenv("RAVE_API_KEY"); apiKey != "" {
+	if apiKey != "" {
 		params.Set("api_key", apiKey)
 	}
 	
-	if lat, ok := arguments["lat"].(float64); ok {
-		params.Set("lat", fmt.Sprintf("%f", lat))
+	if input.Lat != nil {
+		params.Set("lat", fmt.Sprintf("%f", *input.Lat))
 	}
-	if lon, ok := arguments["lon"].(float64); ok {
-		params.Set("lon", fmt.Sprintf("%f", lon))
+	if input.Lon != nil {
+		params.Set("lon", fmt.Sprintf("%f", *input.Lon))
 	}
 	
 	// Call the Lambda API
@@ -364,11 +738,24 @@ func handleCreateList(arguments map[string]interface{}) ToolResult {
 	
 	fmt.Fprintf(os.Stderr, "Making API call to %s\n", fullURL)
 	
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fullURL, nil)
+	if err != nil {
+		return ToolResult{
+			Content: []Content{{
+				Type: "text",
+				Text: fmt.Sprintf("❌ Error building map API request: %s", err.Error()),
+			}},
+			IsError: true,
+		}
+	}
+	
+	progress.Report(1, 4, "Calling map API, this can take up to 30 seconds")
+	
 	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Get(fullURL)
+	resp, err := client.Do(req)
 	if err != nil {
 		return ToolResult{
-			Content: []TextContent{{
+			Content: []Content{{
 				Type: "text",
 				Text: fmt.Sprintf("❌ Network error calling map API: %s", err.Error()),
 			}},
@@ -380,7 +767,7 @@ func handleCreateList(arguments map[string]interface{}) ToolResult {
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return ToolResult{
-			Content: []TextContent{{
+			Content: []Content{{
 				Type: "text",
 				Text: fmt.Sprintf("❌ Error reading API response: %s", err.Error()),
 			}},
@@ -388,10 +775,12 @@ func handleCreateList(arguments map[string]interface{}) ToolResult {
 		}
 	}
 	
-	var result map[string]interface{}
-	if err := json.Unmarshal(body, &result); err != nil {
+	progress.Report(2, 4, "Parsing map result")
+	
+	var response map[string]interface{}
+	if err := json.Unmarshal(body, &response); err != nil {
 		return ToolResult{
-			Content: []TextContent{{
+			Content: []Content{{
 				Type: "text",
 				Text: fmt.Sprintf("❌ Error parsing API response: %s", err.Error()),
 			}},
@@ -399,9 +788,9 @@ func handleCreateList(arguments map[string]interface{}) ToolResult {
 		}
 	}
 	
-	if success, ok := result["success"].(bool); ok && success {
-		mapURL := getString(result, "url")
-		parameters, _ := result["parameters"].(map[string]interface{})
+	if success, ok := response["success"].(bool); ok && success {
+		mapURL := getString(response, "url")
+		parameters, _ := response["parameters"].(map[string]interface{})
 		
 		points := getIntWithDefault(parameters, "points", count)
 		radiusMiles := getIntWithDefault(parameters, "radius_miles", 50)
@@ -409,6 +798,15 @@ func handleCreateList(arguments map[string]interface{}) ToolResult {
 		centerLat := getString(parameters, "center_lat")
 		centerLon := getString(parameters, "center_lon")
 		
+		output := CreateListOutput{
+			MapURL:            mapURL,
+			Points:            points,
+			RadiusMiles:       radiusMiles,
+			ClustersGenerated: clustersGenerated,
+			CenterLat:         getFloat(parameters, "center_lat"),
+			CenterLon:         getFloat(parameters, "center_lon"),
+		}
+		
 		responseText := fmt.Sprintf(`📍 **Physician Distribution Map Created!**
 
 🔗 **MAP LINK: %s**
@@ -425,19 +823,75 @@ func handleCreateList(arguments map[string]interface{}) ToolResult {
 The map shows physician distribution with clustering and is ready for analysis.`, 
 			mapURL, formatNumber(points), radiusMiles, clustersGenerated, centerLat, centerLon, mapURL)
 		
+		content := []Content{{
+			Type: "text",
+			Text: responseText,
+		}, {
+			Type:        "resource_link",
+			URI:         mapURL,
+			Name:        "physician-map",
+			Description: fmt.Sprintf("Map of %s physicians in a %d mile radius", formatNumber(points), radiusMiles),
+			MimeType:    "image/png",
+		}}
+		
+		var imageData []byte
+		if input.IncludeImage {
+			progress.Report(3, 4, "Downloading map image")
+			image, data, err := fetchMapImage(ctx, mapURL)
+			imageData = data
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Could not fetch map image: %s\n", err)
+				content = append(content, Content{
+					Type: "text",
+					Text: fmt.Sprintf("⚠️ The map image could not be downloaded (%s), use the link above instead.", err.Error()),
+				})
+			} else {
+				content = append(content, image)
+			}
+		}
+		
+		// The map is still useful to the caller if it cannot be kept
+		if mapID != "" {
+			if saved, err := completeMap(mapID, output, imageData); err != nil {
+				fmt.Fprintf(os.Stderr, "Could not save map: %s\n", err)
+			} else {
+				mapSaved = true
+				output = saved.CreateListOutput
+				content = append(content, Content{
+					Type:        "resource_link",
+					URI:         mapResourceURI(saved.MapID),
+					Name:        saved.MapID,
+					Description: "This map, saved so it can be read again later without regenerating it",
+					MimeType:    "image/png",
+				})
+				// Its details travel with the result so the client need not
+				// read the resource just to learn what was saved
+				if details, err := json.MarshalIndent(saved, "", "  "); err == nil {
+					content = append(content, Content{
+						Type: "resource",
+						Resource: &ResourceContents{
+							URI:      mapResourceURI(saved.MapID),
+							MimeType: "application/json",
+							Text:     string(details),
+						},
+					})
+				}
+			}
+		}
+		
+		progress.Report(4, 4, "Map ready")
+		
 		return ToolResult{
-			Content: []TextContent{{
-				Type: "text",
-				Text: responseText,
-			}},
+			Content:           content,
+			StructuredContent: output,
 		}
 	} else {
-		errorMsg := getString(result, "error")
+		errorMsg := getString(response, "error")
 		if errorMsg == "" {
 			errorMsg = "Unknown error"
 		}
 		return ToolResult{
-			Content: []TextContent{{
+			Content: []Content{{
 				Type: "text",
 				Text: fmt.Sprintf("❌ Error creating map: %s", errorMsg),
 			}},
@@ -453,6 +907,70 @@ func getString(m map[string]interface{}, key string) string {
 	return ""
 }
 
+// maxMapImageBytes caps how much of a map image is downloaded and inlined.
+const maxMapImageBytes = 5 << 20
+
+// fetchMapImage downloads a generated map and returns it as image content.
+func fetchMapImage(ctx context.Context, mapURL string) (Content, []byte, error) {
+	data, mimeType, err := downloadMapImage(ctx, mapURL)
+	if err != nil {
+		return Content{}, nil, err
+	}
+	
+	return Content{
+		Type:     "image",
+		Data:     base64.StdEncoding.EncodeToString(data),
+		MimeType: mimeType,
+	}, data, nil
+}
+
+// downloadMapImage fetches a generated map, checking that it is an image of
+// a reasonable size.
+func downloadMapImage(ctx context.Context, mapURL string) ([]byte, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, mapURL, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("map server returned %s", resp.Status)
+	}
+	
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxMapImageBytes+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if len(data) > maxMapImageBytes {
+		return nil, "", fmt.Errorf("image is larger than %d MB", maxMapImageBytes>>20)
+	}
+	
+	mimeType := http.DetectContentType(data)
+	if !strings.HasPrefix(mimeType, "image/") {
+		return nil, "", fmt.Errorf("expected an image, got %s", mimeType)
+	}
+	return data, mimeType, nil
+}
+
+// getFloat reads a number that the API may send either as a JSON number or as
+// a numeric string.
+func getFloat(m map[string]interface{}, key string) float64 {
+	switch val := m[key].(type) {
+	case float64:
+		return val
+	case string:
+		f, _ := strconv.ParseFloat(val, 64)
+		return f
+	}
+	return 0
+}
+
 func getInt(m map[string]interface{}, key string) int {
 	if val, ok := m[key].(float64); ok {
 		return int(val)
@@ -461,7 +979,11 @@ func getInt(m map[string]interface{}, key string) int {
 }
 
 func getIntWithDefault(m map[string]interface{}, key string, defaultVal int) int {
-	if val := getInt(m, key); val != 0 {
+	return intWithDefault(getInt(m, key), defaultVal)
+}
+
+func intWithDefault(val, defaultVal int) int {
+	if val != 0 {
 		return val
 	}
 	return defaultVal
@@ -474,20 +996,18 @@ func formatNumber(n int) string {
 	return strconv.Itoa(n)
 }
 
-func sendResponse(id int, result interface{}) {
-	response := JsonRpcResponse{
+func newResponse(id json.RawMessage, result interface{}) *JsonRpcResponse {
+	return &JsonRpcResponse{
 		JsonRpc: "2.0",
 		ID:      id,
 		Result:  result,
 	}
-	
-	data, _ := json.Marshal(response)
-	fmt.Println(string(data))
-	os.Stdout.Sync()
 }
 
-func sendError(id, code int, message string) {
-	response := JsonRpcResponse{
+// newErrorResponse builds an error reply. A nil ID is sent as null, which is
+// what JSON-RPC requires when the request ID could not be determined.
+func newErrorResponse(id json.RawMessage, code int, message string) *JsonRpcResponse {
+	return &JsonRpcResponse{
 		JsonRpc: "2.0",
 		ID:      id,
 		Error: &JsonRpcError{
@@ -495,12 +1015,9 @@ func sendError(id, code int, message string) {
 			Message: message,
 		},
 	}
-	
-	data, _ := json.Marshal(response)
-	fmt.Println(string(data))
-	os.Stdout.Sync()
 }
 
+
 func runDiagnostics() {
 	var messages []string
 	var hasErrors bool
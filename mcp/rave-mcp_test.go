@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseRequest(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		wantCode int // 0 when the request is valid
+		wantID   string
+	}{
+		{name: "request", data: `{"jsonrpc":"2.0","id":1,"method":"ping"}`, wantID: "1"},
+		{name: "string id", data: `{"jsonrpc":"2.0","id":"a","method":"ping"}`, wantID: `"a"`},
+		{name: "null id", data: `{"jsonrpc":"2.0","id":null,"method":"ping"}`, wantID: "null"},
+		{name: "notification", data: `{"jsonrpc":"2.0","method":"notifications/initialized"}`},
+		{name: "not json", data: `{"jsonrpc"`, wantCode: codeParseError},
+		{name: "not an object", data: `42`, wantCode: codeInvalidRequest},
+		{name: "method not a string", data: `{"jsonrpc":"2.0","id":7,"method":1}`, wantCode: codeInvalidRequest, wantID: "7"},
+		{name: "object id", data: `{"jsonrpc":"2.0","id":{},"method":"ping"}`, wantCode: codeInvalidRequest},
+		{name: "boolean id", data: `{"jsonrpc":"2.0","id":true,"method":"ping"}`, wantCode: codeInvalidRequest},
+		{name: "wrong version", data: `{"jsonrpc":"1.0","id":2,"method":"ping"}`, wantCode: codeInvalidRequest, wantID: "2"},
+		{name: "missing version", data: `{"id":2,"method":"ping"}`, wantCode: codeInvalidRequest, wantID: "2"},
+		{name: "missing method", data: `{"jsonrpc":"2.0","id":3}`, wantCode: codeInvalidRequest, wantID: "3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request, errResponse := parseRequest([]byte(tt.data))
+			if tt.wantCode == 0 {
+				if errResponse != nil {
+					t.Fatalf("parseRequest() error = %+v, want none", errResponse.Error)
+				}
+				if string(request.ID) != tt.wantID {
+					t.Errorf("ID = %s, want %s", request.ID, tt.wantID)
+				}
+				return
+			}
+
+			if errResponse == nil || errResponse.Error == nil {
+				t.Fatalf("parseRequest() succeeded, want error %d", tt.wantCode)
+			}
+			if errResponse.Error.Code != tt.wantCode {
+				t.Errorf("error code = %d, want %d", errResponse.Error.Code, tt.wantCode)
+			}
+			if string(errResponse.ID) != tt.wantID && !(tt.wantID == "" && errResponse.ID == nil) {
+				t.Errorf("error ID = %s, want %s", errResponse.ID, tt.wantID)
+			}
+		})
+	}
+}
+
+func TestIsValidID(t *testing.T) {
+	tests := []struct {
+		id   json.RawMessage
+		want bool
+	}{
+		{nil, true},
+		{json.RawMessage(`1`), true},
+		{json.RawMessage(`-1`), true},
+		{json.RawMessage(`1.5`), true},
+		{json.RawMessage(`"abc"`), true},
+		{json.RawMessage(`""`), true},
+		{json.RawMessage(`null`), true},
+		{json.RawMessage(`true`), false},
+		{json.RawMessage(`{}`), false},
+		{json.RawMessage(`[1]`), false},
+	}
+
+	for _, tt := range tests {
+		if got := isValidID(tt.id); got != tt.want {
+			t.Errorf("isValidID(%s) = %v, want %v", tt.id, got, tt.want)
+		}
+	}
+}
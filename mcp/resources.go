@@ -0,0 +1,322 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// resourcePageSize is how many resources resources/list returns at a time.
+const resourcePageSize = 50
+
+const (
+	campaignResourcePrefix = "rave://campaigns/"
+	mapResourcePrefix      = "rave://maps/"
+)
+
+type Resource struct {
+	URI         string `json:"uri"`
+	Name        string `json:"name"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+type ResourceTemplate struct {
+	URITemplate string `json:"uriTemplate"`
+	Name        string `json:"name"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+type ListResourcesParams struct {
+	Cursor string `json:"cursor,omitempty"`
+}
+
+type ListResourcesResult struct {
+	Resources  []Resource `json:"resources"`
+	NextCursor string     `json:"nextCursor,omitempty"`
+}
+
+type ReadResourceParams struct {
+	URI string `json:"uri"`
+}
+
+type ReadResourceResult struct {
+	Contents []ResourceContents `json:"contents"`
+}
+
+// errInvalidCursor is returned by listResources for a cursor it did not hand
+// out.
+var errInvalidCursor = errors.New("invalid cursor")
+
+// ResourceNotFoundError is returned by readResource for a URI that names
+// nothing.
+type ResourceNotFoundError struct {
+	URI string
+}
+
+func (e *ResourceNotFoundError) Error() string {
+	return "Resource not found: " + e.URI
+}
+
+var resourceTemplates = []ResourceTemplate{
+	{
+		URITemplate: campaignResourcePrefix + "{id}",
+		Name:        "campaign",
+		Title:       "Campaign",
+		Description: "A saved campaign as JSON, including its status and version",
+		MimeType:    "application/json",
+	},
+	{
+		URITemplate: mapResourcePrefix + "{id}",
+		Name:        "physician-map",
+		Title:       "Physician Map",
+		Description: "A map generated by create_list: the PNG image followed by its details as JSON. While the map is still generating, only the details are returned.",
+		MimeType:    "image/png",
+	},
+}
+
+func campaignResourceURI(id string) string {
+	return campaignResourcePrefix + id
+}
+
+func mapResourceURI(id string) string {
+	return mapResourcePrefix + id
+}
+
+// listedResource is a resource along with the time it sorts by.
+type listedResource struct {
+	Resource
+	createdAt time.Time
+}
+
+// resourceCursor marks the last resource of a page. Like list_campaigns,
+// pages are keyed on the last item rather than an offset so that new
+// campaigns and maps do not shift later pages.
+type resourceCursor struct {
+	CreatedAt time.Time `json:"t"`
+	URI       string    `json:"uri"`
+}
+
+// listResources returns a page of campaigns and maps, newest first.
+func listResources(ctx context.Context, cursor string) (ListResourcesResult, error) {
+	var after *resourceCursor
+	if cursor != "" {
+		var err error
+		if after, err = decodeResourceCursor(cursor); err != nil {
+			return ListResourcesResult{}, err
+		}
+	}
+
+	all, err := allResources(ctx)
+	if err != nil {
+		return ListResourcesResult{}, err
+	}
+	return pageResources(all, after), nil
+}
+
+func decodeResourceCursor(cursor string) (*resourceCursor, error) {
+	var after resourceCursor
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err == nil {
+		err = json.Unmarshal(data, &after)
+	}
+	if err != nil || after.URI == "" {
+		return nil, errInvalidCursor
+	}
+	return &after, nil
+}
+
+// pageResources returns the page of all that follows the cursor.
+func pageResources(all []listedResource, after *resourceCursor) ListResourcesResult {
+	start := 0
+	if after != nil {
+		start = sort.Search(len(all), func(i int) bool {
+			return newerResourceFirst(listedResource{Resource: Resource{URI: after.URI}, createdAt: after.CreatedAt}, all[i])
+		})
+	}
+	end := min(start+resourcePageSize, len(all))
+
+	result := ListResourcesResult{Resources: make([]Resource, 0, end-start)}
+	for _, r := range all[start:end] {
+		result.Resources = append(result.Resources, r.Resource)
+	}
+	if end < len(all) {
+		last := all[end-1]
+		data, _ := json.Marshal(resourceCursor{CreatedAt: last.createdAt, URI: last.URI})
+		result.NextCursor = base64.RawURLEncoding.EncodeToString(data)
+	}
+	return result
+}
+
+// allResources lists every campaign and map in page order.
+func allResources(ctx context.Context) ([]listedResource, error) {
+	var all []listedResource
+
+	store, err := campaigns()
+	if err != nil {
+		return nil, err
+	}
+	list, err := store.List(ctx, CampaignFilter{})
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range list {
+		all = append(all, listedResource{Resource: campaignResource(c), createdAt: c.CreatedAt})
+	}
+
+	mapStore, err := maps()
+	if err != nil {
+		return nil, err
+	}
+	generated, err := mapStore.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range generated {
+		all = append(all, listedResource{Resource: mapResource(m), createdAt: m.CreatedAt})
+	}
+
+	sort.Slice(all, func(i, j int) bool { return newerResourceFirst(all[i], all[j]) })
+	return all, nil
+}
+
+func newerResourceFirst(a, b listedResource) bool {
+	if !a.createdAt.Equal(b.createdAt) {
+		return a.createdAt.After(b.createdAt)
+	}
+	return a.URI > b.URI
+}
+
+func campaignResource(c Campaign) Resource {
+	return Resource{
+		URI:         campaignResourceURI(c.ID),
+		Name:        c.ID,
+		Title:       c.CampaignName,
+		Description: fmt.Sprintf("%s campaign for %s, version %d", c.Status, c.ClientName, c.Version),
+		MimeType:    "application/json",
+	}
+}
+
+func mapResource(m GeneratedMap) Resource {
+	if !m.ready() {
+		return Resource{
+			URI:         mapResourceURI(m.MapID),
+			Name:        m.MapID,
+			Title:       fmt.Sprintf("Map of %s physicians (generating)", formatNumber(m.Points)),
+			Description: fmt.Sprintf("%s physicians in a %d mile radius, still being generated; subscribe to hear when it is ready", formatNumber(m.Points), m.RadiusMiles),
+			MimeType:    "application/json",
+		}
+	}
+	return Resource{
+		URI:         mapResourceURI(m.MapID),
+		Name:        m.MapID,
+		Title:       fmt.Sprintf("Map of %s physicians", formatNumber(m.Points)),
+		Description: fmt.Sprintf("%s physicians in a %d mile radius around %.4f, %.4f, made %s", formatNumber(m.Points), m.RadiusMiles, m.CenterLat, m.CenterLon, m.CreatedAt.Format("2006-01-02 15:04 MST")),
+		MimeType:    "image/png",
+	}
+}
+
+// readResource returns the contents behind a rave:// URI.
+func readResource(ctx context.Context, uri string) (ReadResourceResult, error) {
+	switch {
+	case strings.HasPrefix(uri, campaignResourcePrefix):
+		store, err := campaigns()
+		if err != nil {
+			return ReadResourceResult{}, err
+		}
+		campaign, err := store.Get(ctx, strings.TrimPrefix(uri, campaignResourcePrefix))
+		if errors.Is(err, ErrCampaignNotFound) {
+			return ReadResourceResult{}, &ResourceNotFoundError{URI: uri}
+		}
+		if err != nil {
+			return ReadResourceResult{}, err
+		}
+
+		data, err := json.MarshalIndent(campaign, "", "  ")
+		if err != nil {
+			return ReadResourceResult{}, err
+		}
+		return ReadResourceResult{Contents: []ResourceContents{{
+			URI:      uri,
+			MimeType: "application/json",
+			Text:     string(data),
+		}}}, nil
+
+	case strings.HasPrefix(uri, mapResourcePrefix):
+		store, err := maps()
+		if err != nil {
+			return ReadResourceResult{}, err
+		}
+		m, err := store.Get(strings.TrimPrefix(uri, mapResourcePrefix))
+		if errors.Is(err, ErrMapNotFound) {
+			return ReadResourceResult{}, &ResourceNotFoundError{URI: uri}
+		}
+		if err != nil {
+			return ReadResourceResult{}, err
+		}
+
+		details, err := json.MarshalIndent(m, "", "  ")
+		if err != nil {
+			return ReadResourceResult{}, err
+		}
+		if !m.ready() {
+			return ReadResourceResult{Contents: []ResourceContents{{
+				URI:      uri,
+				MimeType: "application/json",
+				Text:     string(details),
+			}}}, nil
+		}
+
+		image, err := store.Image(ctx, m)
+		if err != nil {
+			return ReadResourceResult{}, fmt.Errorf("map image is no longer available: %w", err)
+		}
+		return ReadResourceResult{Contents: []ResourceContents{{
+			URI:      uri,
+			MimeType: http.DetectContentType(image),
+			Blob:     base64.StdEncoding.EncodeToString(image),
+		}, {
+			URI:      uri,
+			MimeType: "application/json",
+			Text:     string(details),
+		}}}, nil
+	}
+
+	return ReadResourceResult{}, &ResourceNotFoundError{URI: uri}
+}
+
+// adaptResources drops the parts of resource definitions the session's
+// protocol revision does not know about.
+func (s *Session) adaptResources(list []Resource) []Resource {
+	if s.supports(featureTitle) {
+		return list
+	}
+	adapted := make([]Resource, len(list))
+	for i, r := range list {
+		r.Title = ""
+		adapted[i] = r
+	}
+	return adapted
+}
+
+// adaptResourceTemplates is adaptResources for templates.
+func (s *Session) adaptResourceTemplates(list []ResourceTemplate) []ResourceTemplate {
+	if s.supports(featureTitle) {
+		return list
+	}
+	adapted := make([]ResourceTemplate, len(list))
+	for i, t := range list {
+		t.Title = ""
+		adapted[i] = t
+	}
+	return adapted
+}
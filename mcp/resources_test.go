@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestPageResourcesWalksEveryResourceOnce(t *testing.T) {
+	// A campaign and a map made in the same second each time, with the page
+	// boundary falling between the two halves of such a pair
+	var all []listedResource
+	made := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	for i := 0; i < resourcePageSize+5; i++ {
+		at := made.Add(time.Duration(i/2) * time.Second)
+		uri := campaignResourceURI(fmt.Sprintf("cmp_%03d", i))
+		if i%2 == 1 {
+			uri = mapResourceURI(fmt.Sprintf("map_%03d", i))
+		}
+		all = append(all, listedResource{Resource: Resource{URI: uri}, createdAt: at})
+	}
+	sort.Slice(all, func(i, j int) bool { return newerResourceFirst(all[i], all[j]) })
+
+	seen := make(map[string]bool)
+	var order []string
+	cursor := ""
+	for page := 1; ; page++ {
+		var after *resourceCursor
+		if cursor != "" {
+			var err error
+			if after, err = decodeResourceCursor(cursor); err != nil {
+				t.Fatalf("page %d: cursor %q does not decode: %v", page, cursor, err)
+			}
+		}
+
+		result := pageResources(all, after)
+		if result.NextCursor != "" && len(result.Resources) != resourcePageSize {
+			t.Errorf("page %d has %d resources, want a full page of %d", page, len(result.Resources), resourcePageSize)
+		}
+		for _, r := range result.Resources {
+			if seen[r.URI] {
+				t.Fatalf("page %d repeats %s", page, r.URI)
+			}
+			seen[r.URI] = true
+			order = append(order, r.URI)
+		}
+
+		if cursor = result.NextCursor; cursor == "" {
+			break
+		}
+	}
+
+	if len(order) != len(all) {
+		t.Fatalf("walked %d resources, want %d", len(order), len(all))
+	}
+	for i, r := range all {
+		if order[i] != r.URI {
+			t.Fatalf("resource %d is %s, want %s", i, order[i], r.URI)
+		}
+	}
+}
+
+func TestDecodeResourceCursor(t *testing.T) {
+	tests := []struct {
+		cursor string
+		valid  bool
+	}{
+		{"eyJ0IjoiMjAyNS0wMy0wMVQwOTowMDowMFoiLCJ1cmkiOiJyYXZlOi8vbWFwcy9tYXBfMSJ9", true},
+		{"not base64!", false},
+		{"bm90IGpzb24", false}, // "not json"
+		{"e30", false},         // {}
+		{"bnVsbA", false},      // null
+	}
+
+	for _, tt := range tests {
+		after, err := decodeResourceCursor(tt.cursor)
+		switch {
+		case tt.valid && err != nil:
+			t.Errorf("decodeResourceCursor(%q) error = %v", tt.cursor, err)
+		case tt.valid && after.URI != "rave://maps/map_1":
+			t.Errorf("decodeResourceCursor(%q) URI = %q, want rave://maps/map_1", tt.cursor, after.URI)
+		case !tt.valid && err != errInvalidCursor:
+			t.Errorf("decodeResourceCursor(%q) error = %v, want errInvalidCursor", tt.cursor, err)
+		}
+	}
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// JSONSchema is the subset of JSON Schema used to describe tool inputs.
+type JSONSchema struct {
+	Type                 string                 `json:"type,omitempty"`
+	Description          string                 `json:"description,omitempty"`
+	Format               string                 `json:"format,omitempty"`
+	Properties           map[string]*JSONSchema `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	Items                *JSONSchema            `json:"items,omitempty"`
+	Enum                 []string               `json:"enum,omitempty"`
+	Minimum              *float64               `json:"minimum,omitempty"`
+	Maximum              *float64               `json:"maximum,omitempty"`
+	AdditionalProperties *bool                  `json:"additionalProperties,omitempty"`
+}
+
+// MarshalJSON always writes the properties of an object schema, empty or
+// not, since some clients reject an object schema without them.
+func (s JSONSchema) MarshalJSON() ([]byte, error) {
+	type plain JSONSchema
+	if s.Type != "object" {
+		return json.Marshal(plain(s))
+	}
+
+	properties := s.Properties
+	if properties == nil {
+		properties = map[string]*JSONSchema{}
+	}
+	return json.Marshal(struct {
+		plain
+		Properties map[string]*JSONSchema `json:"properties"`
+	}{plain(s), properties})
+}
+
+// schemaOf derives the schema for T, see schemaFor.
+func schemaOf[T any]() *JSONSchema {
+	return schemaFor(reflect.TypeOf((*T)(nil)).Elem())
+}
+
+// schemaFor derives a JSON Schema from a Go type. Struct fields are named by
+// their json tag and are required unless tagged omitempty. Further keywords
+// come from struct tags:
+//
+//	description:"..."     human-readable description
+//	minimum:"10"          inclusive lower bound for numbers
+//	maximum:"10000"       inclusive upper bound for numbers
+//	enum:"email|social"   allowed string values
+//
+// Objects derived from structs reject properties they do not declare.
+func schemaFor(t reflect.Type) *JSONSchema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if t == reflect.TypeOf(time.Time{}) {
+		return &JSONSchema{Type: "string", Format: "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &JSONSchema{Type: "string"}
+	case reflect.Bool:
+		return &JSONSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &JSONSchema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &JSONSchema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &JSONSchema{Type: "array", Items: schemaFor(t.Elem())}
+	case reflect.Map:
+		return &JSONSchema{Type: "object"}
+	case reflect.Struct:
+		return structSchema(t)
+	default:
+		// interface{} and friends accept anything
+		return &JSONSchema{}
+	}
+}
+
+func structSchema(t reflect.Type) *JSONSchema {
+	closed := false
+	schema := &JSONSchema{
+		Type:                 "object",
+		Properties:           map[string]*JSONSchema{},
+		AdditionalProperties: &closed,
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		property := schemaFor(field.Type)
+		property.Description = field.Tag.Get("description")
+		property.Minimum = tagFloat(t, field, "minimum")
+		property.Maximum = tagFloat(t, field, "maximum")
+		if enum := field.Tag.Get("enum"); enum != "" {
+			property.Enum = strings.Split(enum, "|")
+		}
+
+		schema.Properties[name] = property
+		if !strings.Contains(options, "omitempty") {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+
+	return schema
+}
+
+// tagFloat reads a numeric struct tag. A malformed tag is a programming error
+// and panics when the schema is first built.
+func tagFloat(t reflect.Type, field reflect.StructField, key string) *float64 {
+	value := field.Tag.Get(key)
+	if value == "" {
+		return nil
+	}
+
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		panic(fmt.Sprintf("%s.%s: invalid %s tag %q", t.Name(), field.Name, key, value))
+	}
+	return &f
+}
+
+// SchemaViolation is one way a value fails its schema, located by a JSON
+// Pointer (RFC 6901) into the value.
+type SchemaViolation struct {
+	Pointer string `json:"pointer"`
+	Message string `json:"message"`
+}
+
+func (v SchemaViolation) String() string {
+	pointer := v.Pointer
+	if pointer == "" {
+		pointer = "(arguments)"
+	}
+	return pointer + ": " + v.Message
+}
+
+// Validate checks a value decoded by encoding/json into interface{} against
+// the schema and returns every violation, not just the first. A null for an
+// optional property is treated as if the property were absent.
+func (s *JSONSchema) Validate(value interface{}) []SchemaViolation {
+	var violations []SchemaViolation
+	s.validate(value, "", &violations)
+	return violations
+}
+
+func (s *JSONSchema) validate(value interface{}, pointer string, violations *[]SchemaViolation) {
+	fail := func(format string, args ...interface{}) {
+		*violations = append(*violations, SchemaViolation{Pointer: pointer, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if s.Type != "" && !matchesType(s.Type, value) {
+		fail("expected %s, got %s", s.Type, jsonTypeOf(value))
+		return
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, name := range s.Required {
+			if field, ok := v[name]; !ok || field == nil {
+				*violations = append(*violations, SchemaViolation{
+					Pointer: pointer + "/" + escapePointer(name),
+					Message: "is required",
+				})
+			}
+		}
+
+		for _, name := range sortedKeys(v) {
+			property, declared := s.Properties[name]
+			switch {
+			case declared && v[name] != nil:
+				property.validate(v[name], pointer+"/"+escapePointer(name), violations)
+			case !declared && s.AdditionalProperties != nil && !*s.AdditionalProperties:
+				*violations = append(*violations, SchemaViolation{
+					Pointer: pointer + "/" + escapePointer(name),
+					Message: "is not a known property",
+				})
+			}
+		}
+
+	case []interface{}:
+		if s.Items != nil {
+			for i, item := range v {
+				s.Items.validate(item, fmt.Sprintf("%s/%d", pointer, i), violations)
+			}
+		}
+
+	case string:
+		if len(s.Enum) > 0 && !containsString(s.Enum, v) {
+			fail("must be one of %s", strings.Join(s.Enum, ", "))
+		}
+
+	case float64:
+		if s.Minimum != nil && v < *s.Minimum {
+			fail("must be at least %s", formatFloat(*s.Minimum))
+		}
+		if s.Maximum != nil && v > *s.Maximum {
+			fail("must be at most %s", formatFloat(*s.Maximum))
+		}
+	}
+}
+
+func matchesType(schemaType string, value interface{}) bool {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return schemaType == "object"
+	case []interface{}:
+		return schemaType == "array"
+	case string:
+		return schemaType == "string"
+	case bool:
+		return schemaType == "boolean"
+	case float64:
+		return schemaType == "number" || (schemaType == "integer" && v == math.Trunc(v))
+	case nil:
+		return schemaType == "null"
+	default:
+		return false
+	}
+}
+
+func jsonTypeOf(value interface{}) string {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case nil:
+		return "null"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+// escapePointer escapes a property name for use in a JSON Pointer.
+func escapePointer(name string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(name)
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+type schemaTestInput struct {
+	Name    string   `json:"name"`
+	Count   int      `json:"count,omitempty" minimum:"1" maximum:"10"`
+	Ratio   float64  `json:"ratio,omitempty" minimum:"0"`
+	Channel string   `json:"channel,omitempty" enum:"email|social"`
+	Tags    []string `json:"tags,omitempty"`
+	Odd     string   `json:"a/b~c,omitempty"`
+}
+
+func TestValidate(t *testing.T) {
+	schema := schemaOf[schemaTestInput]()
+
+	tests := []struct {
+		name  string
+		value interface{}
+		want  []SchemaViolation
+	}{
+		{
+			name:  "valid",
+			value: map[string]interface{}{"name": "x", "count": 3.0, "ratio": 0.5, "channel": "email", "tags": []interface{}{"a"}},
+		},
+		{
+			name:  "integral float is an integer",
+			value: map[string]interface{}{"name": "x", "count": 10.0},
+		},
+		{
+			name:  "null optional property",
+			value: map[string]interface{}{"name": "x", "count": nil},
+		},
+		{
+			name:  "not an object",
+			value: []interface{}{},
+			want:  []SchemaViolation{{Pointer: "", Message: "expected object, got array"}},
+		},
+		{
+			name:  "missing required property",
+			value: map[string]interface{}{},
+			want:  []SchemaViolation{{Pointer: "/name", Message: "is required"}},
+		},
+		{
+			name:  "null required property",
+			value: map[string]interface{}{"name": nil},
+			want:  []SchemaViolation{{Pointer: "/name", Message: "is required"}},
+		},
+		{
+			name:  "unknown property",
+			value: map[string]interface{}{"name": "x", "extra": true},
+			want:  []SchemaViolation{{Pointer: "/extra", Message: "is not a known property"}},
+		},
+		{
+			name:  "wrong type",
+			value: map[string]interface{}{"name": 5.0},
+			want:  []SchemaViolation{{Pointer: "/name", Message: "expected string, got integer"}},
+		},
+		{
+			name:  "fraction for an integer",
+			value: map[string]interface{}{"name": "x", "count": 2.5},
+			want:  []SchemaViolation{{Pointer: "/count", Message: "expected integer, got number"}},
+		},
+		{
+			name:  "below minimum",
+			value: map[string]interface{}{"name": "x", "count": 0.0, "ratio": -0.1},
+			want: []SchemaViolation{
+				{Pointer: "/count", Message: "must be at least 1"},
+				{Pointer: "/ratio", Message: "must be at least 0"},
+			},
+		},
+		{
+			name:  "above maximum",
+			value: map[string]interface{}{"name": "x", "count": 11.0},
+			want:  []SchemaViolation{{Pointer: "/count", Message: "must be at most 10"}},
+		},
+		{
+			name:  "not in enum",
+			value: map[string]interface{}{"name": "x", "channel": "fax"},
+			want:  []SchemaViolation{{Pointer: "/channel", Message: "must be one of email, social"}},
+		},
+		{
+			name:  "bad array item",
+			value: map[string]interface{}{"name": "x", "tags": []interface{}{"a", 1.0}},
+			want:  []SchemaViolation{{Pointer: "/tags/1", Message: "expected string, got integer"}},
+		},
+		{
+			name:  "pointer escaping",
+			value: map[string]interface{}{"name": "x", "a/b~c": false},
+			want:  []SchemaViolation{{Pointer: "/a~1b~0c", Message: "expected string, got boolean"}},
+		},
+		{
+			name:  "every violation is reported",
+			value: map[string]interface{}{"count": "3", "extra": 1.0},
+			want: []SchemaViolation{
+				{Pointer: "/name", Message: "is required"},
+				{Pointer: "/count", Message: "expected integer, got string"},
+				{Pointer: "/extra", Message: "is not a known property"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := schema.Validate(tt.value)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Validate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSchemaMarshalKeepsEmptyProperties(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema *JSONSchema
+		want   string
+	}{
+		{
+			name:   "no parameters",
+			schema: schemaOf[struct{}](),
+			want:   `{"type":"object","additionalProperties":false,"properties":{}}`,
+		},
+		{
+			name:   "bare object",
+			schema: &JSONSchema{Type: "object"},
+			want:   `{"type":"object","properties":{}}`,
+		},
+		{
+			name:   "not an object",
+			schema: &JSONSchema{Type: "string"},
+			want:   `{"type":"string"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.Marshal(tt.schema)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Marshal() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,646 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// maxConcurrentRequests bounds how many requests a session works on at once.
+// Further requests wait for a free worker.
+const maxConcurrentRequests = 8
+
+// maxQueuedRequests bounds how many requests may wait for a worker before a
+// stdio session stops reading its input.
+const maxQueuedRequests = 64
+
+// errRequestCancelled is the context cause for requests the client withdrew
+// with notifications/cancelled. Their responses are never sent.
+var errRequestCancelled = errors.New("request cancelled by client")
+
+// Session is one client connection, whatever the transport. Requests are
+// handled concurrently on a bounded pool of workers. Messages the server sends
+// on its own (and, for stdio, every reply) are queued on outbound and written
+// by a single consumer so frames never interleave on the wire.
+type Session struct {
+	id     string
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	outbound   chan []byte
+	lossy      bool
+	done       chan struct{}
+	closeOnce  sync.Once
+	writerDone chan struct{}
+	workers    chan struct{}
+	queued     chan struct{}
+	inFlight   sync.WaitGroup
+
+	mu         sync.Mutex
+	cancelers  map[string]*inFlightRequest
+	pending    map[string]chan clientResponse
+	lastSentID int64
+	subscribed map[string]bool
+	state      sessionState
+	streaming  bool
+	lastActive time.Time
+
+	// Set by initialize, see protocol.go
+	protocolVersion    string
+	clientCapabilities ClientCapabilities
+	clientInfo         Implementation
+}
+
+// inFlightRequest lets notifications/cancelled abort a request. Requests are
+// registered before they wait for a worker, so cancel is nil until one picks
+// the request up, and cancelled remembers a cancellation that came first.
+// Both are guarded by Session.mu.
+type inFlightRequest struct {
+	cancel    context.CancelCauseFunc
+	cancelled bool
+}
+
+// newSession creates a session and registers it. The transport is responsible
+// for draining outbound; lossy sessions are those whose transport may have no
+// listener connected, see send.
+func newSession(lossy bool) *Session {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Session{
+		id:         newSessionID(),
+		ctx:        ctx,
+		cancel:     cancel,
+		outbound:   make(chan []byte, 64),
+		lossy:      lossy,
+		done:       make(chan struct{}),
+		workers:    make(chan struct{}, maxConcurrentRequests),
+		queued:     make(chan struct{}, maxQueuedRequests),
+		cancelers:  make(map[string]*inFlightRequest),
+		pending:    make(map[string]chan clientResponse),
+		subscribed: make(map[string]bool),
+		lastActive: time.Now(),
+	}
+	sessions.add(s)
+	return s
+}
+
+// newStdioSession creates a session whose messages are written to w as
+// newline-delimited JSON.
+func newStdioSession(w io.Writer) *Session {
+	s := newSession(false)
+	s.writerDone = make(chan struct{})
+	go s.writeLoop(w)
+	return s
+}
+
+func newSessionID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// dispatch hands a raw message to a worker goroutine which queues the reply
+// for the writer. Requests wait for a free worker off the caller's goroutine,
+// so dispatch only blocks once maxQueuedRequests are already waiting. The
+// caller must not modify data afterwards. Once the session is closing,
+// messages are answered inline, which for requests means a shutdown error.
+// Notifications and responses to the server's own requests skip the pool:
+// they are quick, and a cancellation or reply may be what frees a worker.
+func (s *Session) dispatch(data []byte) {
+	if s.deliverResponse(data) || s.deliverNotification(data) {
+		return
+	}
+
+	if !s.begin() {
+		if reply := s.handleMessage(refused(s.ctx), data); reply != nil {
+			s.send(reply)
+		}
+		return
+	}
+
+	// Until initialize has been handled, messages run in arrival order so no
+	// request can overtake it and slip past the lifecycle check
+	if s.currentState() == stateUninitialized {
+		s.workers <- struct{}{}
+		s.process(data)
+		return
+	}
+
+	forget := s.expect(data)
+	s.queued <- struct{}{}
+	go func() {
+		defer forget()
+		s.workers <- struct{}{}
+		<-s.queued
+		s.process(data)
+	}()
+}
+
+func (s *Session) process(data []byte) {
+	defer s.release()
+
+	if reply := s.handleMessage(s.ctx, data); reply != nil {
+		s.send(reply)
+	}
+}
+
+// call handles a raw message on the caller's goroutine and returns the reply,
+// for transports that answer within the HTTP exchange. The work is cancelled
+// when either ctx or the session ends.
+func (s *Session) call(ctx context.Context, data []byte) interface{} {
+	if s.deliverResponse(data) || s.deliverNotification(data) {
+		return nil
+	}
+
+	forget := s.expect(data)
+	defer forget()
+
+	s.workers <- struct{}{}
+	if !s.begin() {
+		<-s.workers
+		return s.handleMessage(refused(ctx), data)
+	}
+	defer s.release()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := context.AfterFunc(s.ctx, cancel)
+	defer stop()
+
+	return s.handleMessage(ctx, data)
+}
+
+func (s *Session) release() {
+	<-s.workers
+	s.inFlight.Done()
+}
+
+// touch records activity so idle HTTP sessions can be reaped.
+func (s *Session) touch() {
+	s.mu.Lock()
+	s.lastActive = time.Now()
+	s.mu.Unlock()
+}
+
+// idleSince reports when the session was last used, or the zero time while a
+// client holds a stream open.
+func (s *Session) idleSince() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.streaming {
+		return time.Time{}
+	}
+	return s.lastActive
+}
+
+// attachStream claims the session's outbound queue for an event stream. Only
+// one stream may consume it at a time.
+func (s *Session) attachStream() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.streaming {
+		return false
+	}
+	s.streaming = true
+	return true
+}
+
+func (s *Session) detachStream() {
+	s.mu.Lock()
+	s.streaming = false
+	s.lastActive = time.Now()
+	s.mu.Unlock()
+}
+
+// expect registers the requests in data before they wait for a worker, so
+// that a cancellation arriving in the meantime is not lost. The returned func
+// forgets those that never started; call it once data has been handled.
+func (s *Session) expect(data []byte) func() {
+	ids := requestIDs(data)
+	entries := make([]*inFlightRequest, len(ids))
+
+	s.mu.Lock()
+	for i, id := range ids {
+		entries[i] = &inFlightRequest{}
+		s.cancelers[requestKey(id)] = entries[i]
+	}
+	s.mu.Unlock()
+
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for i, id := range ids {
+			if key := requestKey(id); s.cancelers[key] == entries[i] {
+				delete(s.cancelers, key)
+			}
+		}
+	}
+}
+
+// requestIDs returns the IDs of the requests in a raw message or batch.
+// Anything malformed is left for handleMessage to report.
+func requestIDs(data []byte) []json.RawMessage {
+	data = bytes.TrimSpace(data)
+	messages := []json.RawMessage{data}
+	if len(data) > 0 && data[0] == '[' {
+		if json.Unmarshal(data, &messages) != nil {
+			return nil
+		}
+	}
+
+	var ids []json.RawMessage
+	for _, message := range messages {
+		var envelope struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}
+		if json.Unmarshal(message, &envelope) == nil && envelope.ID != nil && envelope.Method != "" && isValidID(envelope.ID) {
+			ids = append(ids, envelope.ID)
+		}
+	}
+	return ids
+}
+
+// track derives a cancellable context for a request so that a later
+// notifications/cancelled naming its ID can abort it. If the request was
+// cancelled while it waited for a worker, the context is already done. The
+// returned func must be called once the request has finished.
+func (s *Session) track(ctx context.Context, id json.RawMessage) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	key := requestKey(id)
+
+	s.mu.Lock()
+	entry, ok := s.cancelers[key]
+	if !ok || entry.cancel != nil {
+		// Not registered by expect, or the ID is being reused while an
+		// earlier request with it is still running
+		entry = &inFlightRequest{}
+		s.cancelers[key] = entry
+	}
+	entry.cancel = cancel
+	cancelled := entry.cancelled
+	s.mu.Unlock()
+
+	if cancelled {
+		cancel(errRequestCancelled)
+	}
+
+	return ctx, func() {
+		s.mu.Lock()
+		// A reused ID may already belong to a newer request
+		if s.cancelers[key] == entry {
+			delete(s.cancelers, key)
+		}
+		s.mu.Unlock()
+		cancel(nil)
+	}
+}
+
+// cancelRequest aborts the request with the given ID, whether it is running
+// or still waiting for a worker. It reports whether there was one.
+func (s *Session) cancelRequest(id json.RawMessage) bool {
+	s.mu.Lock()
+	entry, ok := s.cancelers[requestKey(id)]
+	var cancel context.CancelCauseFunc
+	if ok {
+		entry.cancelled = true
+		cancel = entry.cancel
+	}
+	s.mu.Unlock()
+
+	if cancel != nil {
+		cancel(errRequestCancelled)
+	}
+	return ok
+}
+
+func (s *Session) handleNotification(request JsonRpcRequest) {
+	switch request.Method {
+	case "notifications/initialized":
+		if !s.transition(stateInitializing, stateReady) {
+			fmt.Fprintf(os.Stderr, "Session %s: ignoring initialized notification in state %s\n", s.id, s.currentState())
+		}
+
+	case "notifications/cancelled":
+		var params struct {
+			RequestID json.RawMessage `json:"requestId"`
+			Reason    string          `json:"reason,omitempty"`
+		}
+		if err := json.Unmarshal(request.Params, &params); err != nil || params.RequestID == nil {
+			fmt.Fprintf(os.Stderr, "Ignoring malformed cancellation: %s\n", request.Params)
+			return
+		}
+		// The request may have finished already, which is not an error
+		if s.cancelRequest(params.RequestID) {
+			fmt.Fprintf(os.Stderr, "Cancelled request %s: %s\n", params.RequestID, params.Reason)
+		}
+	}
+}
+
+// deliverNotification handles data inline if it is a single valid
+// notification and reports whether it was.
+func (s *Session) deliverNotification(data []byte) bool {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || data[0] != '{' {
+		return false
+	}
+	request, errResponse := parseRequest(data)
+	if errResponse != nil || !request.IsNotification() {
+		return false
+	}
+	s.handleNotification(request)
+	return true
+}
+
+// requestKey normalises a raw ID so that 1 and " 1" name the same request
+// while 1 and "1" stay distinct.
+func requestKey(id json.RawMessage) string {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, id); err != nil {
+		return string(id)
+	}
+	return buf.String()
+}
+
+// errNoReply is returned by request when the session ends before the client
+// answers.
+var errNoReply = errors.New("session closed before the client replied")
+
+// clientResponse is the client's answer to a request the server sent.
+type clientResponse struct {
+	ID     json.RawMessage `json:"id"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  *JsonRpcError   `json:"error,omitempty"`
+}
+
+// ClientError is a JSON-RPC error the client returned for a server request.
+type ClientError struct {
+	*JsonRpcError
+}
+
+func (e *ClientError) Error() string {
+	return fmt.Sprintf("client error %d: %s", e.Code, e.Message)
+}
+
+// request sends a request to the client as part of the request running under
+// ctx and waits for the result. It gives up when ctx is done or the session
+// ends.
+func (s *Session) request(ctx context.Context, method string, params interface{}) (json.RawMessage, error) {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+
+	replies := make(chan clientResponse, 1)
+	s.mu.Lock()
+	s.lastSentID++
+	id := json.RawMessage(fmt.Sprintf(`"rave-%d"`, s.lastSentID))
+	s.pending[requestKey(id)] = replies
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.pending, requestKey(id))
+		s.mu.Unlock()
+	}()
+
+	s.sendRelated(ctx, JsonRpcRequest{JsonRpc: "2.0", ID: id, Method: method, Params: raw})
+
+	select {
+	case reply := <-replies:
+		if reply.Error != nil {
+			return nil, &ClientError{reply.Error}
+		}
+		return reply.Result, nil
+	case <-ctx.Done():
+		// Let the client stop asking its user
+		s.send(JsonRpcNotification{
+			JsonRpc: "2.0",
+			Method:  "notifications/cancelled",
+			Params:  map[string]interface{}{"requestId": id, "reason": context.Cause(ctx).Error()},
+		})
+		return nil, context.Cause(ctx)
+	case <-s.ctx.Done():
+		return nil, errNoReply
+	}
+}
+
+// deliverResponse hands a response from the client to the request waiting for
+// it and reports whether data was a response at all. Responses nobody is
+// waiting for, because the request timed out or was cancelled, are dropped.
+func (s *Session) deliverResponse(data []byte) bool {
+	var message struct {
+		clientResponse
+		Method *string `json:"method"`
+	}
+	if json.Unmarshal(data, &message) != nil || message.Method != nil || message.ID == nil {
+		return false
+	}
+	if message.Result == nil && message.Error == nil {
+		return false
+	}
+
+	s.mu.Lock()
+	replies, ok := s.pending[requestKey(message.ID)]
+	s.mu.Unlock()
+
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Session %s: ignoring response to unknown request %s\n", s.id, message.ID)
+		return true
+	}
+	select {
+	case replies <- message.clientResponse:
+	default:
+	}
+	return true
+}
+
+type sinkKey struct{}
+
+// withSink routes messages related to a request (progress and the like) to
+// sink instead of the session's outbound queue. The Streamable HTTP transport
+// uses this to send them on the request's own event stream.
+func withSink(ctx context.Context, sink func(message interface{})) context.Context {
+	return context.WithValue(ctx, sinkKey{}, sink)
+}
+
+// sendRelated sends a message that belongs to the request running under ctx.
+func (s *Session) sendRelated(ctx context.Context, message interface{}) {
+	if sink, ok := ctx.Value(sinkKey{}).(func(message interface{})); ok {
+		sink(message)
+		return
+	}
+	s.send(message)
+}
+
+// canReach reports whether a message sent with sendRelated under ctx would
+// reach the client now: the request has its own event stream, or the
+// session's queue has a listener. Streamable HTTP requests answered with plain
+// JSON only have the latter while the client holds a GET stream open.
+func (s *Session) canReach(ctx context.Context) bool {
+	if _, ok := ctx.Value(sinkKey{}).(func(message interface{})); ok || !s.lossy {
+		return true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.streaming
+}
+
+// send encodes a message and queues it for the session's consumer. Lossy
+// sessions, whose consumer may not be connected, drop messages rather than
+// block once the queue is full.
+func (s *Session) send(message interface{}) {
+	data, err := json.Marshal(message)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error encoding message: %s\n", err)
+		return
+	}
+
+	if s.lossy {
+		select {
+		case s.outbound <- data:
+		case <-s.done:
+		default:
+			fmt.Fprintf(os.Stderr, "Session %s has no listener, dropping message\n", s.id)
+		}
+		return
+	}
+
+	// Once the session is cancelled nobody may be left to read
+	select {
+	case s.outbound <- data:
+	case <-s.done:
+	case <-s.ctx.Done():
+	}
+}
+
+// writeLoop is the only place that writes to a stdio client. Output is
+// flushed whenever the queue drains so replies are not held back behind
+// buffering.
+func (s *Session) writeLoop(w io.Writer) {
+	defer close(s.writerDone)
+
+	out := bufio.NewWriter(w)
+	write := func(data []byte) {
+		out.Write(data)
+		out.WriteByte('\n')
+		if len(s.outbound) == 0 {
+			if err := out.Flush(); err != nil {
+				fmt.Fprintf(os.Stderr, "Error writing response: %s\n", err)
+			}
+		}
+	}
+
+	for {
+		select {
+		case data := <-s.outbound:
+			write(data)
+		case <-s.done:
+			// Everything queued before close still goes out
+			for {
+				select {
+				case data := <-s.outbound:
+					write(data)
+				default:
+					out.Flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// close waits for in-flight requests to finish and for their output to be
+// written, then unregisters the session. Callers wanting to abort rather than
+// drain cancel the session first; see also shutdown.
+func (s *Session) close() {
+	s.closeOnce.Do(func() {
+		s.mu.Lock()
+		s.state = stateClosing
+		s.mu.Unlock()
+
+		sessions.remove(s.id)
+		s.inFlight.Wait()
+		close(s.done)
+		if s.writerDone != nil {
+			<-s.writerDone
+		}
+		s.cancel()
+	})
+}
+
+// sessionRegistry tracks every live session so HTTP requests can find theirs
+// by ID.
+type sessionRegistry struct {
+	mu       sync.Mutex
+	sessions map[string]*Session
+}
+
+var sessions = &sessionRegistry{sessions: make(map[string]*Session)}
+
+func (r *sessionRegistry) add(s *Session) {
+	r.mu.Lock()
+	r.sessions[s.id] = s
+	r.mu.Unlock()
+}
+
+func (r *sessionRegistry) get(id string) (*Session, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.sessions[id]
+	return s, ok
+}
+
+func (r *sessionRegistry) remove(id string) {
+	r.mu.Lock()
+	delete(r.sessions, id)
+	r.mu.Unlock()
+}
+
+func (r *sessionRegistry) all() []*Session {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	all := make([]*Session, 0, len(r.sessions))
+	for _, s := range r.sessions {
+		all = append(all, s)
+	}
+	return all
+}
+
+// broadcast sends a notification to every session past initialize. Sessions
+// that have not initialized yet will get the current state when they do.
+func (r *sessionRegistry) broadcast(method string, params interface{}) {
+	for _, s := range r.all() {
+		if s.pastInitialize() {
+			s.send(JsonRpcNotification{JsonRpc: "2.0", Method: method, Params: params})
+		}
+	}
+}
+
+// pastInitialize reports whether the session has answered initialize and so
+// should hear about changes from now on.
+func (s *Session) pastInitialize() bool {
+	switch s.currentState() {
+	case stateInitializing, stateReady:
+		return true
+	}
+	return false
+}
+
+// notifyToolsChanged tells clients to fetch tools/list again.
+func notifyToolsChanged() {
+	sessions.broadcast("notifications/tools/list_changed", nil)
+}
+
+// notifyPromptsChanged tells clients to fetch prompts/list again.
+func notifyPromptsChanged() {
+	sessions.broadcast("notifications/prompts/list_changed", nil)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// serveStdio runs a session over stdin/stdout until stdin closes or ctx is
+// done, then drains in-flight requests.
+func serveStdio(ctx context.Context) {
+	session := newStdioSession(os.Stdout)
+	readerDone := make(chan struct{})
+
+	go func() {
+		defer close(readerDone)
+		reader := newMessageReader(os.Stdin)
+
+		for {
+			message, err := reader.Next()
+			if err == nil {
+				session.dispatch(message)
+				continue
+			}
+
+			var framingErr *framingError
+			switch {
+			case errors.As(err, &framingErr):
+				fmt.Fprintf(os.Stderr, "Skipping malformed input on stdin: %s\n", err)
+				session.send(newErrorResponse(nil, codeParseError, "Parse error"))
+			case errors.Is(err, io.EOF):
+				return
+			case errors.Is(err, io.ErrUnexpectedEOF):
+				fmt.Fprintln(os.Stderr, "Stdin closed in the middle of a message, discarding it")
+				return
+			default:
+				fmt.Fprintf(os.Stderr, "Error reading from stdin, closing session: %s\n", err)
+				return
+			}
+		}
+	}()
+
+	select {
+	case <-readerDone:
+	case <-ctx.Done():
+		fmt.Fprintln(os.Stderr, "Received shutdown signal, draining in-flight requests")
+	}
+
+	session.shutdown(shutdownGrace)
+}
+
+// messageReader decodes JSON-RPC messages from a stream with no limit on their
+// size. Messages are normally one per line, but any whitespace between them
+// is accepted.
+type messageReader struct {
+	src *bufio.Reader
+	dec *json.Decoder
+}
+
+// framingError reports input that was not valid JSON. The reader has skipped
+// past it and can keep going.
+type framingError struct {
+	err error
+}
+
+func (e *framingError) Error() string {
+	return "malformed JSON: " + e.err.Error()
+}
+
+func (e *framingError) Unwrap() error {
+	return e.err
+}
+
+func newMessageReader(r io.Reader) *messageReader {
+	src := bufio.NewReader(r)
+	return &messageReader{src: src, dec: json.NewDecoder(src)}
+}
+
+// Next returns the next message. After a *framingError the rest of the
+// offending line has been discarded and Next can be called again; any other
+// error, including io.EOF, is final.
+func (r *messageReader) Next() (json.RawMessage, error) {
+	var message json.RawMessage
+	err := r.dec.Decode(&message)
+	if err == nil {
+		return message, nil
+	}
+
+	var syntaxErr *json.SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		return nil, err
+	}
+
+	// A decoder is stuck after a syntax error, so start a fresh one at the
+	// next line, which is where the next message should begin
+	rest := bufio.NewReader(io.MultiReader(r.dec.Buffered(), r.src))
+	if err := skipLine(rest); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	r.src = rest
+	r.dec = json.NewDecoder(rest)
+
+	return nil, &framingError{err: syntaxErr}
+}
+
+// skipLine discards input up to and including the end of the next line that
+// has anything other than whitespace on it.
+func skipLine(r *bufio.Reader) error {
+	for {
+		c, err := r.ReadByte()
+		if err != nil {
+			return err
+		}
+		switch c {
+		case ' ', '\t', '\r', '\n':
+			continue
+		}
+		_, err = r.ReadBytes('\n')
+		return err
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestMessageReaderRecovers(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		// want lists what each call to Next returns: a message, or "!" for
+		// a framing error
+		want []string
+	}{
+		{
+			name:  "one per line",
+			input: `{"a":1}` + "\n" + `{"b":2}` + "\n",
+			want:  []string{`{"a":1}`, `{"b":2}`},
+		},
+		{
+			name:  "whitespace between messages",
+			input: "\n  {\"a\":1}\r\n\n\t{\"b\":2}",
+			want:  []string{`{"a":1}`, `{"b":2}`},
+		},
+		{
+			name:  "garbage line",
+			input: "not json\n" + `{"a":1}` + "\n",
+			want:  []string{"!", `{"a":1}`},
+		},
+		{
+			name:  "broken message",
+			input: `{"a":}` + "\n" + `{"b":2}` + "\n",
+			want:  []string{"!", `{"b":2}`},
+		},
+		{
+			name:  "error at the start of a line",
+			input: `{"a":1}` + "\n" + `}` + "\n" + `{"b":2}` + "\n",
+			want:  []string{`{"a":1}`, "!", `{"b":2}`},
+		},
+		{
+			name:  "consecutive bad lines",
+			input: "x\ny\n" + `{"a":1}` + "\n",
+			want:  []string{"!", "!", `{"a":1}`},
+		},
+		{
+			name:  "bad last line without newline",
+			input: `{"a":1}` + "\n" + `{oops`,
+			want:  []string{`{"a":1}`, "!"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := newMessageReader(strings.NewReader(tt.input))
+			for i, want := range tt.want {
+				message, err := reader.Next()
+				var framing *framingError
+				switch {
+				case want == "!" && !errors.As(err, &framing):
+					t.Fatalf("Next #%d = %s, %v; want a framing error", i+1, message, err)
+				case want != "!" && (err != nil || string(message) != want):
+					t.Fatalf("Next #%d = %s, %v; want %s", i+1, message, err, want)
+				}
+			}
+			if message, err := reader.Next(); err != io.EOF {
+				t.Fatalf("Next at end = %s, %v; want io.EOF", message, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// subscribe starts sending notifications/resources/updated for uri.
+func (s *Session) subscribe(uri string) {
+	s.mu.Lock()
+	s.subscribed[uri] = true
+	s.mu.Unlock()
+}
+
+func (s *Session) unsubscribe(uri string) {
+	s.mu.Lock()
+	delete(s.subscribed, uri)
+	s.mu.Unlock()
+}
+
+func (s *Session) isSubscribed(uri string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.subscribed[uri]
+}
+
+// subscribableURI reports whether uri names a resource the server can send
+// updates for. The resource need not exist yet.
+func subscribableURI(uri string) bool {
+	for _, prefix := range []string{campaignResourcePrefix, mapResourcePrefix} {
+		if id, ok := strings.CutPrefix(uri, prefix); ok && id != "" && !strings.Contains(id, "/") {
+			return true
+		}
+	}
+	return false
+}
+
+// notifyResourceUpdated tells every session subscribed to uri that it has
+// changed and should be read again.
+func notifyResourceUpdated(uri string) {
+	for _, s := range sessions.all() {
+		if s.pastInitialize() && s.isSubscribed(uri) {
+			s.send(JsonRpcNotification{
+				JsonRpc: "2.0",
+				Method:  "notifications/resources/updated",
+				Params:  map[string]string{"uri": uri},
+			})
+		}
+	}
+}
+
+// notifyResourcesChanged tells clients to fetch resources/list again.
+func notifyResourcesChanged() {
+	sessions.broadcast("notifications/resources/list_changed", nil)
+}
+
+// campaignPollInterval is how often campaigns.jsonl is checked for changes
+// made by other copies of the server.
+const campaignPollInterval = 2 * time.Second
+
+// announcedVersions holds the version of every campaign clients have been
+// told about, so each change is announced once whether this process made it
+// or found it in the file.
+var (
+	announcedMu       sync.Mutex
+	announcedVersions = make(map[string]int)
+)
+
+// rememberCampaigns records campaigns as already known without telling
+// anyone.
+func rememberCampaigns(list []Campaign) {
+	announcedMu.Lock()
+	defer announcedMu.Unlock()
+	for _, c := range list {
+		if c.Version > announcedVersions[c.ID] {
+			announcedVersions[c.ID] = c.Version
+		}
+	}
+}
+
+// announceCampaign tells clients about a campaign that is new or has
+// changed since they last heard about it.
+func announceCampaign(c Campaign) {
+	announcedMu.Lock()
+	known, seen := announcedVersions[c.ID]
+	if seen && known >= c.Version {
+		announcedMu.Unlock()
+		return
+	}
+	announcedVersions[c.ID] = c.Version
+	announcedMu.Unlock()
+
+	if !seen {
+		notifyResourcesChanged()
+		return
+	}
+	notifyResourceUpdated(campaignResourceURI(c.ID))
+}
+
+// watchCampaigns announces changes other copies of the server make to the
+// shared campaign file until ctx is done. Campaigns cannot be deleted, so
+// only additions and changes are ever announced.
+func watchCampaigns(ctx context.Context) {
+	dir, err := dataDir()
+	if err != nil {
+		return
+	}
+	path := filepath.Join(dir, "campaigns.jsonl")
+
+	// What is already there when the server starts is not news
+	if _, err := os.Stat(path); err == nil {
+		store, err := campaigns()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Not watching campaigns: %s\n", err)
+			return
+		}
+		list, err := store.List(ctx, CampaignFilter{})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Not watching campaigns: %s\n", err)
+			return
+		}
+		rememberCampaigns(list)
+	}
+
+	go watchFile(ctx, path, campaignPollInterval, func() {
+		store, err := campaigns()
+		if err != nil {
+			return
+		}
+		list, err := store.List(ctx, CampaignFilter{})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading campaigns after a change: %s\n", err)
+			return
+		}
+		for _, c := range list {
+			announceCampaign(c)
+		}
+	})
+}
+
+// notifyingCampaignStore tells clients about every campaign created or
+// changed through it.
+type notifyingCampaignStore struct {
+	CampaignStore
+}
+
+func (s notifyingCampaignStore) Create(ctx context.Context, campaign Campaign) (Campaign, error) {
+	campaign, err := s.CampaignStore.Create(ctx, campaign)
+	if err == nil {
+		announceCampaign(campaign)
+	}
+	return campaign, err
+}
+
+func (s notifyingCampaignStore) Update(ctx context.Context, id string, version int, change func(*Campaign) error) (Campaign, error) {
+	campaign, err := s.CampaignStore.Update(ctx, id, version, change)
+	if err == nil {
+		announceCampaign(campaign)
+	}
+	return campaign, err
+}
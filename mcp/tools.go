@@ -0,0 +1,327 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// toolHandler runs a tool against its raw arguments.
+type toolHandler func(ctx context.Context, arguments json.RawMessage) ToolResult
+
+// toolSource records where a tool was defined, so a config reload can replace
+// its own tools without touching the built-in ones.
+type toolSource int
+
+const (
+	sourceBuiltin toolSource = iota
+	sourceConfig
+)
+
+// ToolAnnotations describe how a tool behaves so clients can decide how much
+// confirmation to ask for. They are hints: nothing checks that a tool lives
+// up to them. DestructiveHint and IdempotentHint only mean something for
+// tools that are not read-only, and OpenWorldHint marks tools that reach
+// systems outside this server.
+type ToolAnnotations struct {
+	Title           string `json:"title,omitempty"`
+	ReadOnlyHint    bool   `json:"readOnlyHint"`
+	DestructiveHint bool   `json:"destructiveHint"`
+	IdempotentHint  bool   `json:"idempotentHint"`
+	OpenWorldHint   bool   `json:"openWorldHint"`
+}
+
+// UnmarshalJSON applies the MCP defaults for hints that are left out, which
+// assume the worst: a tool may change things destructively and reach the
+// outside world.
+func (a *ToolAnnotations) UnmarshalJSON(data []byte) error {
+	type plain ToolAnnotations
+	annotations := plain{DestructiveHint: true, OpenWorldHint: true}
+	if err := json.Unmarshal(data, &annotations); err != nil {
+		return err
+	}
+	*a = ToolAnnotations(annotations)
+	return nil
+}
+
+// writes reports whether a tool may change anything, including saving new
+// data. Only tools annotated as read-only are assumed not to.
+func (t Tool) writes() bool {
+	return t.Annotations == nil || !t.Annotations.ReadOnlyHint
+}
+
+type registeredTool struct {
+	tool    Tool
+	handler toolHandler
+	source  toolSource
+
+	// unavailable says why the tool is switched off, or is empty if it is
+	// on
+	unavailable string
+}
+
+// ToolRegistry holds the tools a server offers. tools/list and tools/call are
+// both answered from it, so a tool's schema and its handler cannot drift
+// apart. Tools are listed in registration order; unavailable tools are
+// neither listed nor callable.
+//
+// Changes made through update call onChange once if the visible tool list is
+// different afterwards.
+type ToolRegistry struct {
+	mu       sync.RWMutex
+	order    []string
+	byName   map[string]*registeredTool
+	onChange func()
+}
+
+func NewToolRegistry() *ToolRegistry {
+	return &ToolRegistry{byName: make(map[string]*registeredTool)}
+}
+
+// RegisterTool adds a tool whose input schema is derived from In (see
+// schemaFor). Arguments are decoded into a fresh In before handler runs.
+// Registering a name twice replaces the earlier tool.
+func RegisterTool[In any](r *ToolRegistry, tool Tool, handler func(ctx context.Context, input In) ToolResult) {
+	tool.InputSchema = schemaOf[In]()
+
+	r.add(&registeredTool{
+		tool: tool,
+		handler: func(ctx context.Context, arguments json.RawMessage) ToolResult {
+			var input In
+			if len(arguments) > 0 && string(arguments) != "null" {
+				if err := json.Unmarshal(arguments, &input); err != nil {
+					return ToolResult{
+						Content: []Content{{
+							Type: "text",
+							Text: fmt.Sprintf("❌ Invalid arguments for %s: %s", tool.Name, err),
+						}},
+						IsError: true,
+					}
+				}
+			}
+			return handler(ctx, input)
+		},
+	})
+}
+
+func (r *ToolRegistry) add(entry *registeredTool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.byName[entry.tool.Name]; !exists {
+		r.order = append(r.order, entry.tool.Name)
+	}
+	r.byName[entry.tool.Name] = entry
+}
+
+// removeSource drops every tool that came from source.
+func (r *ToolRegistry) removeSource(source toolSource) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	order := r.order[:0]
+	for _, name := range r.order {
+		if r.byName[name].source == source {
+			delete(r.byName, name)
+			continue
+		}
+		order = append(order, name)
+	}
+	r.order = order
+}
+
+// setUnavailable switches a tool off for the given reason, or back on if the
+// reason is empty.
+func (r *ToolRegistry) setUnavailable(name, reason string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if entry, ok := r.byName[name]; ok {
+		entry.unavailable = reason
+	}
+}
+
+// all returns every registered tool definition, available or not.
+func (r *ToolRegistry) all() []Tool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	tools := make([]Tool, 0, len(r.order))
+	for _, name := range r.order {
+		tools = append(tools, r.byName[name].tool)
+	}
+	return tools
+}
+
+// update runs change and calls onChange if it altered what tools/list
+// returns. Changes that cancel out, like a config reload that leaves the tools
+// as they were, do not notify anyone.
+func (r *ToolRegistry) update(change func()) {
+	before, _ := json.Marshal(r.List())
+	change()
+	after, _ := json.Marshal(r.List())
+
+	if string(before) != string(after) && r.onChange != nil {
+		r.onChange()
+	}
+}
+
+// List returns the definitions of the available tools.
+func (r *ToolRegistry) List() []Tool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	tools := make([]Tool, 0, len(r.order))
+	for _, name := range r.order {
+		if entry := r.byName[name]; entry.unavailable == "" {
+			tools = append(tools, entry.tool)
+		}
+	}
+	return tools
+}
+
+// lookup returns a copy of a tool's entry, taken under the lock since
+// setUnavailable changes entries in place.
+func (r *ToolRegistry) lookup(name string) (registeredTool, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	entry, ok := r.byName[name]
+	if !ok {
+		return registeredTool{}, false
+	}
+	return *entry, true
+}
+
+// ArgumentsError reports tool arguments that do not match the tool's input
+// schema. It lists every violation so the caller can fix them all at once.
+type ArgumentsError struct {
+	Tool       string            `json:"tool"`
+	Violations []SchemaViolation `json:"violations"`
+}
+
+func (e *ArgumentsError) Error() string {
+	problems := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		problems[i] = v.String()
+	}
+	return fmt.Sprintf("Invalid arguments for %s: %s", e.Tool, strings.Join(problems, "; "))
+}
+
+// Call validates the arguments against the tool's input schema and runs it.
+// Arguments that fail validation return an *ArgumentsError without running
+// the tool. Unknown tools are reported as a tool error so the model can see
+// and correct the name, and so are tools that are switched off.
+func (r *ToolRegistry) Call(ctx context.Context, name string, arguments json.RawMessage) (ToolResult, error) {
+	entry, ok := r.lookup(name)
+	if !ok {
+		return ToolResult{
+			Content: []Content{{
+				Type: "text",
+				Text: fmt.Sprintf("Unknown tool: %s", name),
+			}},
+			IsError: true,
+		}, nil
+	}
+	if entry.unavailable != "" {
+		return ToolResult{
+			Content: []Content{{
+				Type: "text",
+				Text: fmt.Sprintf("❌ The %s tool is not available: %s", name, entry.unavailable),
+			}},
+			IsError: true,
+		}, nil
+	}
+
+	// Missing arguments are validated as an empty object, so required
+	// properties are still reported
+	var value interface{} = map[string]interface{}{}
+	if len(arguments) > 0 && string(arguments) != "null" {
+		if err := json.Unmarshal(arguments, &value); err != nil {
+			return ToolResult{}, &ArgumentsError{Tool: name, Violations: []SchemaViolation{{Message: err.Error()}}}
+		}
+	}
+	if violations := entry.tool.InputSchema.Validate(value); len(violations) > 0 {
+		return ToolResult{}, &ArgumentsError{Tool: name, Violations: violations}
+	}
+
+	// The handler gets the arguments as validated. Encoding them again
+	// writes integral numbers like 1e3 or 1000.0 as 1000, which the schema
+	// accepts as an integer and so must decode into an int field.
+	arguments, err := json.Marshal(value)
+	if err != nil {
+		return ToolResult{}, &ArgumentsError{Tool: name, Violations: []SchemaViolation{{Message: err.Error()}}}
+	}
+
+	result := entry.handler(ctx, arguments)
+	checkOutput(entry.tool, result)
+	return result, nil
+}
+
+// checkOutput logs structured content that does not match the tool's output
+// schema. That is a bug in the tool, not something the caller can fix, so the
+// result is still returned.
+func checkOutput(tool Tool, result ToolResult) {
+	if tool.OutputSchema == nil || result.StructuredContent == nil || result.IsError {
+		return
+	}
+
+	data, err := json.Marshal(result.StructuredContent)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Tool %s returned structured content that cannot be encoded: %s\n", tool.Name, err)
+		return
+	}
+	var value interface{}
+	json.Unmarshal(data, &value)
+
+	for _, v := range tool.OutputSchema.Validate(value) {
+		fmt.Fprintf(os.Stderr, "Tool %s returned structured content not matching its output schema: %s\n", tool.Name, v)
+	}
+}
+
+// adaptTools drops the parts of tool definitions the session's protocol
+// revision does not know about.
+func (s *Session) adaptTools(list []Tool) []Tool {
+	adapted := make([]Tool, len(list))
+	for i, tool := range list {
+		if !s.supports(featureStructuredOutput) {
+			tool.OutputSchema = nil
+		}
+
+		// Before tools had a title of their own it went in the annotations
+		if !s.supports(featureTitle) {
+			if tool.Annotations != nil && tool.Title != "" {
+				annotations := *tool.Annotations
+				annotations.Title = tool.Title
+				tool.Annotations = &annotations
+			}
+			tool.Title = ""
+		}
+		if !s.supports(featureToolAnnotations) {
+			tool.Annotations = nil
+		}
+		adapted[i] = tool
+	}
+	return adapted
+}
+
+// adaptResult drops the parts of a tool result the session's protocol
+// revision does not know about. The text content always carries the full
+// result, so nothing is lost for older clients.
+func (s *Session) adaptResult(result ToolResult) ToolResult {
+	if !s.supports(featureStructuredOutput) {
+		result.StructuredContent = nil
+	}
+
+	if !s.supports(featureResourceLinks) {
+		content := make([]Content, 0, len(result.Content))
+		for _, c := range result.Content {
+			if c.Type != "resource_link" {
+				content = append(content, c)
+			}
+		}
+		result.Content = content
+	}
+	return result
+}
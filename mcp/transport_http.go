@@ -0,0 +1,397 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	sessionHeader  = "Mcp-Session-Id"
+	protocolHeader = "Mcp-Protocol-Version"
+
+	// maxHTTPBodyBytes caps a single POSTed message or batch
+	maxHTTPBodyBytes = 10 << 20
+
+	// sessionIdleTimeout is how long an HTTP session may go unused before it
+	// is closed. Sessions with a connected event stream never expire.
+	sessionIdleTimeout = 30 * time.Minute
+
+	// sseKeepAlive keeps proxies from closing quiet event streams
+	sseKeepAlive = 25 * time.Second
+)
+
+// serveHTTP serves the MCP Streamable HTTP transport on /mcp, and the older
+// HTTP+SSE transport on /sse and /messages, until the server fails or ctx is
+// done. On shutdown every session drains its in-flight requests before the
+// listener closes, so their replies still reach the clients.
+func serveHTTP(ctx context.Context, addr string) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/mcp", handleStreamableHTTP)
+	mux.HandleFunc("/sse", handleLegacySSE)
+	mux.HandleFunc("/messages", handleLegacyMessage)
+
+	server := &http.Server{Addr: addr, Handler: mux}
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.ListenAndServe()
+	}()
+
+	go reapIdleSessions()
+
+	fmt.Fprintf(os.Stderr, "Rave MCP server listening on %s\n", addr)
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	fmt.Fprintln(os.Stderr, "Received shutdown signal, draining in-flight requests")
+
+	var wg sync.WaitGroup
+	for _, session := range sessions.all() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			session.shutdown(shutdownGrace)
+		}()
+	}
+	wg.Wait()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return server.Shutdown(shutdownCtx)
+}
+
+func handleStreamableHTTP(w http.ResponseWriter, r *http.Request) {
+	if !allowedOrigin(r) {
+		http.Error(w, "Forbidden origin", http.StatusForbidden)
+		return
+	}
+
+	// Clients on 2025-06-18 and later repeat the negotiated revision on every
+	// request after initialize
+	if version := r.Header.Get(protocolHeader); version != "" && !isSupportedProtocolVersion(version) {
+		http.Error(w, "Unsupported protocol version "+version, http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		handleMCPPost(w, r)
+	case http.MethodGet:
+		handleMCPStream(w, r)
+	case http.MethodDelete:
+		handleMCPDelete(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleMCPPost handles one client message or batch. Requests are answered in
+// the response body, either as JSON or, when the client accepts it, as an
+// event stream that also carries progress for those requests.
+func handleMCPPost(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxHTTPBodyBytes))
+	if err != nil {
+		http.Error(w, "Error reading request body", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	hasRequest, isInitialize := inspectMessage(body)
+
+	var session *Session
+	if id := r.Header.Get(sessionHeader); id != "" {
+		var ok bool
+		if session, ok = sessions.get(id); !ok || !session.lossy {
+			http.Error(w, "Unknown session", http.StatusNotFound)
+			return
+		}
+	} else if isInitialize {
+		session = newSession(true)
+	} else {
+		http.Error(w, "Missing "+sessionHeader+" header", http.StatusBadRequest)
+		return
+	}
+	session.touch()
+	w.Header().Set(sessionHeader, session.id)
+
+	// Notifications and responses get no reply body
+	if !hasRequest {
+		session.call(r.Context(), body)
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	if acceptsEventStream(r) {
+		streamReply(w, r, session, body)
+		return
+	}
+
+	reply := session.call(r.Context(), body)
+	if reply == nil {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	data, err := json.Marshal(reply)
+	if err != nil {
+		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+// streamReply answers a POST with an event stream: messages related to the
+// request as they happen, then the reply itself.
+func streamReply(w http.ResponseWriter, r *http.Request, session *Session, body []byte) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	events := make(chan []byte, 16)
+	ctx = withSink(ctx, func(message interface{}) {
+		data, err := json.Marshal(message)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error encoding message: %s\n", err)
+			return
+		}
+		select {
+		case events <- data:
+		case <-ctx.Done():
+		}
+	})
+
+	replies := make(chan interface{}, 1)
+	go func() {
+		replies <- session.call(ctx, body)
+	}()
+
+	stream := newSSEWriter(w)
+	for {
+		select {
+		case data := <-events:
+			stream.event("message", data)
+
+		case reply := <-replies:
+			for len(events) > 0 {
+				stream.event("message", <-events)
+			}
+			if reply != nil {
+				if data, err := json.Marshal(reply); err == nil {
+					stream.event("message", data)
+				}
+			}
+			return
+		}
+	}
+}
+
+// handleMCPStream opens the standalone event stream that carries messages the
+// server sends on its own, such as list-changed notifications.
+func handleMCPStream(w http.ResponseWriter, r *http.Request) {
+	session, ok := lookupSession(w, r)
+	if !ok {
+		return
+	}
+
+	if !acceptsEventStream(r) {
+		http.Error(w, "Client must accept text/event-stream", http.StatusNotAcceptable)
+		return
+	}
+
+	if !session.attachStream() {
+		http.Error(w, "Session already has an event stream", http.StatusConflict)
+		return
+	}
+	defer session.detachStream()
+
+	pumpEventsTo(newSSEWriter(w), r, session)
+}
+
+// pumpEventsTo forwards the session's outbound queue to an event stream until
+// the client disconnects or the session ends.
+func pumpEventsTo(stream *sseWriter, r *http.Request, session *Session) {
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case data := <-session.outbound:
+			if err := stream.event("message", data); err != nil {
+				return
+			}
+		case <-keepAlive.C:
+			if err := stream.comment("keepalive"); err != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		case <-session.done:
+			return
+		}
+	}
+}
+
+// handleMCPDelete ends a session at the client's request, aborting anything
+// still running for it.
+func handleMCPDelete(w http.ResponseWriter, r *http.Request) {
+	session, ok := lookupSession(w, r)
+	if !ok {
+		return
+	}
+
+	session.cancel()
+	session.close()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// lookupSession finds the session named by the request header, writing the
+// error response itself when there is none.
+func lookupSession(w http.ResponseWriter, r *http.Request) (*Session, bool) {
+	id := r.Header.Get(sessionHeader)
+	if id == "" {
+		http.Error(w, "Missing "+sessionHeader+" header", http.StatusBadRequest)
+		return nil, false
+	}
+
+	// Only Streamable HTTP sessions are lossy; SSE sessions are addressed
+	// through /messages instead
+	session, ok := sessions.get(id)
+	if !ok || !session.lossy {
+		http.Error(w, "Unknown session", http.StatusNotFound)
+		return nil, false
+	}
+
+	session.touch()
+	return session, true
+}
+
+// reapIdleSessions closes HTTP sessions whose clients went away without
+// deleting them.
+func reapIdleSessions() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		for _, session := range sessions.all() {
+			idle := session.idleSince()
+			if !session.lossy || idle.IsZero() || time.Since(idle) < sessionIdleTimeout {
+				continue
+			}
+			fmt.Fprintf(os.Stderr, "Closing idle session %s\n", session.id)
+			session.cancel()
+			session.close()
+		}
+	}
+}
+
+// inspectMessage reports whether a raw message or batch contains anything that
+// expects a reply, and whether it is an initialize request. Malformed input
+// counts as a request so the parse error is sent back.
+func inspectMessage(data []byte) (hasRequest, isInitialize bool) {
+	type envelope struct {
+		ID     json.RawMessage `json:"id"`
+		Method string          `json:"method"`
+	}
+
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '[' {
+		var batch []envelope
+		if err := json.Unmarshal(data, &batch); err != nil || len(batch) == 0 {
+			return true, false
+		}
+		for _, message := range batch {
+			if message.ID != nil && message.Method != "" {
+				return true, false
+			}
+		}
+		return false, false
+	}
+
+	var message envelope
+	if err := json.Unmarshal(data, &message); err != nil {
+		return true, false
+	}
+	if message.Method == "" {
+		// A response to a server-initiated request
+		return false, false
+	}
+	return message.ID != nil, message.Method == "initialize"
+}
+
+func acceptsEventStream(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+}
+
+// allowedOrigin guards against DNS rebinding: browsers always send Origin, and
+// it must name the host being served or a loopback address.
+func allowedOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+
+	switch u.Hostname() {
+	case "localhost", "127.0.0.1", "::1":
+		return true
+	}
+	return u.Host == r.Host
+}
+
+// sseWriter writes Server-Sent Events, flushing after each one.
+type sseWriter struct {
+	w       io.Writer
+	flusher http.Flusher
+}
+
+func newSSEWriter(w http.ResponseWriter) *sseWriter {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	flusher, _ := w.(http.Flusher)
+	stream := &sseWriter{w: w, flusher: flusher}
+	stream.flush()
+	return stream
+}
+
+// event writes one event. data must not contain newlines, which holds for
+// anything produced by json.Marshal.
+func (s *sseWriter) event(name string, data []byte) error {
+	if _, err := fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", name, data); err != nil {
+		return err
+	}
+	s.flush()
+	return nil
+}
+
+func (s *sseWriter) comment(text string) error {
+	if _, err := fmt.Fprintf(s.w, ": %s\n\n", text); err != nil {
+		return err
+	}
+	s.flush()
+	return nil
+}
+
+func (s *sseWriter) flush() {
+	if s.flusher != nil {
+		s.flusher.Flush()
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+)
+
+// The 2024-11-05 HTTP+SSE transport: a client opens GET /sse, learns where to
+// POST from the initial "endpoint" event, and receives every reply on that
+// stream. Each stream is its own session, so replies only ever reach the
+// client that asked.
+
+// handleLegacySSE opens a session for the lifetime of the event stream.
+func handleLegacySSE(w http.ResponseWriter, r *http.Request) {
+	if !allowedOrigin(r) {
+		http.Error(w, "Forbidden origin", http.StatusForbidden)
+		return
+	}
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	session := newSession(false)
+	session.attachStream()
+	defer func() {
+		session.cancel()
+		session.close()
+	}()
+
+	stream := newSSEWriter(w)
+	endpoint := fmt.Sprintf("/messages?sessionId=%s", session.id)
+	if err := stream.event("endpoint", []byte(endpoint)); err != nil {
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "SSE session %s connected\n", session.id)
+	pumpEventsTo(stream, r, session)
+	fmt.Fprintf(os.Stderr, "SSE session %s disconnected\n", session.id)
+}
+
+// handleLegacyMessage accepts a message for an SSE session. The reply is
+// delivered on that session's stream, not in this response.
+func handleLegacyMessage(w http.ResponseWriter, r *http.Request) {
+	if !allowedOrigin(r) {
+		http.Error(w, "Forbidden origin", http.StatusForbidden)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	session, ok := sessions.get(r.URL.Query().Get("sessionId"))
+	if !ok || session.lossy {
+		http.Error(w, "Unknown session", http.StatusNotFound)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxHTTPBodyBytes))
+	if err != nil {
+		http.Error(w, "Error reading request body", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	session.touch()
+	session.dispatch(body)
+	w.WriteHeader(http.StatusAccepted)
+}